@@ -0,0 +1,78 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"io"
+	"math"
+	"math/bits"
+)
+
+// AnomalyDeviations is the number of standard deviations above the mean a window must score to be anomalous
+const AnomalyDeviations = 2
+
+// AnomalyRegion is a range of bytes flagged as anomalous
+type AnomalyRegion struct {
+	Start int
+	End   int
+	Score float32
+}
+
+// AnomalyScan trains a model incrementally on a stream and flags the windows whose per byte complexity
+// exceeds the mean by AnomalyDeviations standard deviations. Each byte is scored before it is
+// learned, so a window is measured against everything seen before it.
+func AnomalyScan(r io.Reader, window int) []AnomalyRegion {
+	if window <= 0 {
+		return nil
+	}
+
+	model, ctxt := NewCDF16(), NewContext16(CDF16Depth)
+	buffer, regions := make([]byte, window), make([]AnomalyRegion, 0, 8)
+	offset := 0
+	for {
+		n, err := io.ReadFull(r, buffer)
+		if n > 0 {
+			var total uint64
+			for _, b := range buffer[:n] {
+				s := int(b)
+				m := model.Model(ctxt)
+				total += uint64(bits.Len16(m[s+1] - m[s]))
+				model.Update(uint16(s), ctxt)
+			}
+			regions = append(regions, AnomalyRegion{
+				Start: offset,
+				End:   offset + n,
+				Score: float32(model.Config.Fixed+1) - (float32(total) / float32(n)),
+			})
+			offset += n
+		}
+		if err != nil {
+			break
+		}
+	}
+	if len(regions) == 0 {
+		return nil
+	}
+
+	mean, variance := 0.0, 0.0
+	for _, region := range regions {
+		mean += float64(region.Score)
+	}
+	mean /= float64(len(regions))
+	for _, region := range regions {
+		diff := float64(region.Score) - mean
+		variance += diff * diff
+	}
+	variance /= float64(len(regions))
+	threshold := mean + AnomalyDeviations*math.Sqrt(variance)
+
+	anomalies := make([]AnomalyRegion, 0, 8)
+	for _, region := range regions {
+		if float64(region.Score) > threshold {
+			anomalies = append(anomalies, region)
+		}
+	}
+	return anomalies
+}
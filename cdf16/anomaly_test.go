@@ -0,0 +1,26 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestAnomalyScan(t *testing.T) {
+	const window, start, end = 256, 8192, 8192 + 512
+	input := append([]byte{}, curie(t, 16384)...)
+	rand.New(rand.NewSource(1)).Read(input[start:end])
+	anomalies := AnomalyScan(bytes.NewReader(input), window)
+	if len(anomalies) == 0 {
+		t.Fatal("the noise isn't flagged")
+	}
+	for _, anomaly := range anomalies {
+		if anomaly.End <= start || anomaly.Start >= end {
+			t.Fatalf("the structured bytes %d to %d are flagged", anomaly.Start, anomaly.End)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"sort"
+)
+
+// Children16 are the children of a context node keyed by context symbol
+type Children16 interface {
+	// Get returns the child for a symbol or nil
+	Get(s uint16) *Node16
+	// Set sets the child for a symbol
+	Set(s uint16, n *Node16)
+	// Delete removes the child for a symbol
+	Delete(s uint16)
+	// Clear removes all of the children
+	Clear()
+	// Len is the number of children
+	Len() int
+	// Each calls f for each child
+	Each(f func(s uint16, n *Node16))
+	// Bytes estimates the memory used to store the children, not counting the children themselves
+	Bytes() int
+}
+
+// MapChildren16 are children stored in a map
+type MapChildren16 map[uint16]*Node16
+
+// Get returns the child for a symbol or nil
+func (m MapChildren16) Get(s uint16) *Node16 {
+	return m[s]
+}
+
+// Set sets the child for a symbol
+func (m MapChildren16) Set(s uint16, n *Node16) {
+	m[s] = n
+}
+
+// Delete removes the child for a symbol
+func (m MapChildren16) Delete(s uint16) {
+	delete(m, s)
+}
+
+// Clear removes all of the children
+func (m MapChildren16) Clear() {
+	for s := range m {
+		delete(m, s)
+	}
+}
+
+// Len is the number of children
+func (m MapChildren16) Len() int {
+	return len(m)
+}
+
+// Each calls f for each child
+func (m MapChildren16) Each(f func(s uint16, n *Node16)) {
+	for s, n := range m {
+		f(s, n)
+	}
+}
+
+// Bytes estimates the memory used by the map: a header and buckets of 8 entries that are grown in
+// powers of two to keep an average load of 6.5 entries
+func (m MapChildren16) Bytes() int {
+	const header, bucket = 48, 8 + 8*2 + 8*8 + 8
+	buckets := 1
+	for float64(len(m)) > 6.5*float64(buckets) {
+		buckets *= 2
+	}
+	return header + buckets*bucket
+}
+
+// SliceChildren16 are children stored in a slice sorted by symbol and found by binary search
+type SliceChildren16 struct {
+	Keys  []uint16
+	Nodes []*Node16
+}
+
+func (c *SliceChildren16) search(s uint16) int {
+	return sort.Search(len(c.Keys), func(i int) bool {
+		return c.Keys[i] >= s
+	})
+}
+
+// Get returns the child for a symbol or nil
+func (c *SliceChildren16) Get(s uint16) *Node16 {
+	i := c.search(s)
+	if i < len(c.Keys) && c.Keys[i] == s {
+		return c.Nodes[i]
+	}
+	return nil
+}
+
+// Set sets the child for a symbol
+func (c *SliceChildren16) Set(s uint16, n *Node16) {
+	i := c.search(s)
+	if i < len(c.Keys) && c.Keys[i] == s {
+		c.Nodes[i] = n
+		return
+	}
+	c.Keys = append(c.Keys, 0)
+	c.Nodes = append(c.Nodes, nil)
+	copy(c.Keys[i+1:], c.Keys[i:])
+	copy(c.Nodes[i+1:], c.Nodes[i:])
+	c.Keys[i], c.Nodes[i] = s, n
+}
+
+// Delete removes the child for a symbol
+func (c *SliceChildren16) Delete(s uint16) {
+	i := c.search(s)
+	if i < len(c.Keys) && c.Keys[i] == s {
+		last := len(c.Keys) - 1
+		copy(c.Keys[i:], c.Keys[i+1:])
+		copy(c.Nodes[i:], c.Nodes[i+1:])
+		c.Nodes[last] = nil
+		c.Keys, c.Nodes = c.Keys[:last], c.Nodes[:last]
+	}
+}
+
+// Clear removes all of the children
+func (c *SliceChildren16) Clear() {
+	for i := range c.Nodes {
+		c.Nodes[i] = nil
+	}
+	c.Keys, c.Nodes = c.Keys[:0], c.Nodes[:0]
+}
+
+// Len is the number of children
+func (c *SliceChildren16) Len() int {
+	return len(c.Keys)
+}
+
+// Each calls f for each child in symbol order
+func (c *SliceChildren16) Each(f func(s uint16, n *Node16)) {
+	for i, s := range c.Keys {
+		f(s, c.Nodes[i])
+	}
+}
+
+// Bytes estimates the memory used by the slices
+func (c *SliceChildren16) Bytes() int {
+	return 48 + 2*cap(c.Keys) + 8*cap(c.Nodes)
+}
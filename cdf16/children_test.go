@@ -0,0 +1,39 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"testing"
+)
+
+func TestSliceChildren(t *testing.T) {
+	input, cfg := curie(t, 1024), DefaultCDF16Config()
+	expected := NewComplexityWithConfig(cfg).Complexity(input)
+	cfg.SliceChildren = true
+	if c := NewComplexityWithConfig(cfg).Complexity(input); c != expected {
+		t.Fatalf("the complexity with slice children is %f, not %f", c, expected)
+	}
+}
+
+func benchmarkChildren(b *testing.B, slice bool) {
+	input, cfg := curie(b, 1024), DefaultCDF16Config()
+	cfg.SliceChildren = slice
+	c := NewComplexityWithConfig(cfg)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Reset()
+		c.Complexity(input)
+	}
+}
+
+func BenchmarkChildrenMap(b *testing.B) {
+	benchmarkChildren(b, false)
+}
+
+func BenchmarkChildrenSlice(b *testing.B) {
+	benchmarkChildren(b, true)
+}
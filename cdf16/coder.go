@@ -0,0 +1,150 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"sort"
+)
+
+const (
+	// coderTop is the range below which the coder renormalizes
+	coderTop = 1 << 24
+)
+
+// rangeEncoder is a range encoder with carry propagation
+type rangeEncoder struct {
+	low       uint64
+	rng       uint32
+	cache     byte
+	cacheSize int
+	output    []byte
+}
+
+func newRangeEncoder() *rangeEncoder {
+	return &rangeEncoder{
+		rng:       0xFFFFFFFF,
+		cacheSize: 1,
+		output:    make([]byte, 0, 8),
+	}
+}
+
+func (e *rangeEncoder) shiftLow() {
+	if uint32(e.low) < 0xFF000000 || e.low>>32 != 0 {
+		carry, temp := byte(e.low>>32), e.cache
+		for {
+			e.output = append(e.output, temp+carry)
+			temp = 0xFF
+			e.cacheSize--
+			if e.cacheSize == 0 {
+				break
+			}
+		}
+		e.cache = byte(uint32(e.low) >> 24)
+	}
+	e.cacheSize++
+	e.low = uint64(uint32(e.low) << 8)
+}
+
+func (e *rangeEncoder) encode(start, size uint32, fixed uint) {
+	e.rng >>= fixed
+	e.low += uint64(start) * uint64(e.rng)
+	e.rng *= size
+	for e.rng < coderTop {
+		e.rng <<= 8
+		e.shiftLow()
+	}
+}
+
+func (e *rangeEncoder) flush() []byte {
+	for i := 0; i < 5; i++ {
+		e.shiftLow()
+	}
+	return e.output
+}
+
+// rangeDecoder is the matching range decoder
+type rangeDecoder struct {
+	code  uint32
+	rng   uint32
+	input []byte
+}
+
+func newRangeDecoder(input []byte) *rangeDecoder {
+	d := rangeDecoder{
+		rng:   0xFFFFFFFF,
+		input: input,
+	}
+	for i := 0; i < 5; i++ {
+		d.code = d.code<<8 | uint32(d.next())
+	}
+	return &d
+}
+
+func (d *rangeDecoder) next() byte {
+	if len(d.input) == 0 {
+		return 0
+	}
+	b := d.input[0]
+	d.input = d.input[1:]
+	return b
+}
+
+func (d *rangeDecoder) decode(model []uint16, fixed uint) uint16 {
+	d.rng >>= fixed
+	value := d.code / d.rng
+	if scale := uint32(1) << fixed; value >= scale {
+		value = scale - 1
+	}
+	s := sort.Search(len(model)-1, func(i int) bool {
+		return uint32(model[i+1]) > value
+	})
+	start, size := uint32(model[s]), uint32(model[s+1]-model[s])
+	d.code -= start * d.rng
+	d.rng *= size
+	for d.rng < coderTop {
+		d.code = d.code<<8 | uint32(d.next())
+		d.rng <<= 8
+	}
+	return uint16(s)
+}
+
+// Encode compresses the input with a range coder driven by the adaptive model. The model is
+// trained as it goes, so the data must be decoded by a fresh model built the same way. Bytes outside of
+// the alphabet are folded into its last symbol, so they decode as that symbol
+func (c *CDF16) Encode(input []byte) []byte {
+	encoder, ctxt, fixed := newRangeEncoder(), NewContext16(c.Config.Depth), uint(c.Config.Fixed)
+	for _, b := range input {
+		s := c.Config.fold(int(b))
+		model := c.Model(ctxt)
+		encoder.encode(uint32(model[s]), uint32(model[s+1]-model[s]), fixed)
+		c.Update(s, ctxt)
+	}
+	return encoder.flush()
+}
+
+// CompressionRatio is the size of the input range coded with a clone of the model over the size
+// of the input, so the model itself isn't changed. An empty input has a ratio of 1
+func (c *CDF16) CompressionRatio(input []byte) float64 {
+	if len(input) == 0 {
+		return 1
+	}
+	return float64(len(c.Clone().Encode(input))) / float64(len(input))
+}
+
+// Decode decompresses n bytes encoded by Encode, the symbols of an alphabet larger than a byte are
+// folded into the last byte
+func (c *CDF16) Decode(data []byte, n int) []byte {
+	decoder, ctxt, fixed := newRangeDecoder(data), NewContext16(c.Config.Depth), uint(c.Config.Fixed)
+	output := make([]byte, 0, n)
+	for i := 0; i < n; i++ {
+		s := decoder.decode(c.Model(ctxt), fixed)
+		if s > 255 {
+			s = 255
+		}
+		output = append(output, byte(s))
+		c.Update(s, ctxt)
+	}
+	return output
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestCoderRoundTrip(t *testing.T) {
+	inputs := map[string][]byte{
+		"curie":    curie(t, 1024),
+		"empty":    {},
+		"repeated": bytes.Repeat([]byte{'a'}, 1024),
+		"extremes": {0, 255, 255, 0, 0, 255},
+	}
+	for name, input := range inputs {
+		encoded := NewCDF16().Encode(input)
+		decoded := NewCDF16().Decode(encoded, len(input))
+		if !bytes.Equal(decoded, input) {
+			t.Fatalf("%s: the decoded input differs", name)
+		}
+		t.Logf("%s: %d bytes are encoded in %d bytes", name, len(input), len(encoded))
+	}
+	if encoded := NewCDF16().Encode(inputs["curie"]); len(encoded) >= 1024 {
+		t.Fatalf("curie is encoded in %d bytes", len(encoded))
+	}
+}
+
+func TestCompressionRatio(t *testing.T) {
+	model := NewCDF16()
+	if ratio := model.CompressionRatio(bytes.Repeat([]byte("abcd"), 1024)); ratio >= .1 {
+		t.Fatalf("the repetitive input has a ratio of %f", ratio)
+	}
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+	if ratio := model.CompressionRatio(random); ratio < .95 {
+		t.Fatalf("the random input has a ratio of %f", ratio)
+	}
+	if ratio := model.CompressionRatio(nil); ratio != 1 {
+		t.Fatalf("the empty input has a ratio of %f", ratio)
+	}
+	if model.Clock != 0 {
+		t.Fatal("the compression ratio updates the model")
+	}
+}
+
+func TestCoderSmallAlphabet(t *testing.T) {
+	cfg := DefaultCDF16Config()
+	cfg.Size = 16
+	input := []byte("hello world")
+	folded := make([]byte, len(input))
+	for i, b := range input {
+		folded[i] = byte(cfg.fold(int(b)))
+	}
+	encoded := NewCDF16WithConfig(cfg).Encode(input)
+	if decoded := NewCDF16WithConfig(cfg).Decode(encoded, len(input)); !bytes.Equal(decoded, folded) {
+		t.Fatalf("the input decodes as %v, not the folded %v", decoded, folded)
+	}
+	if ratio := NewCDF16WithConfig(cfg).CompressionRatio(input); ratio <= 0 {
+		t.Fatalf("the compression ratio is %f", ratio)
+	}
+}
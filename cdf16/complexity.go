@@ -0,0 +1,707 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cdf16 is a context based cumulative distributive function model and the complexity
+// estimator and range coder built on it
+package cdf16
+
+import (
+	"math/bits"
+)
+
+const (
+	// CDF16Fixed is the shift for 16 bit coders
+	CDF16Fixed = 16 - 3
+	// CDF16Scale is the scale for 16 bit coder
+	CDF16Scale = 1 << CDF16Fixed
+	// CDF16Rate is the damping factor for 16 bit coder
+	CDF16Rate = 5
+	// CDF16Size is the size of the cdf
+	CDF16Size = 256
+	// CDF16Depth is the depth of the context tree
+	CDF16Depth = 2
+)
+
+// CDF16Config are the parameters of a CDF16 model
+type CDF16Config struct {
+	// Fixed is the shift for the coder, the cdf sums to 1 << Fixed and must fit in 16 bits
+	Fixed int
+	// Rate is the damping factor of model updates
+	Rate int
+	// Size is the size of the alphabet, it must not exceed 1 << Fixed
+	Size int
+	// Depth is the depth of the context tree
+	Depth int
+	// SliceChildren stores the children of a node in a sorted slice instead of a map
+	SliceChildren bool
+	// MaxChildren caps the number of children of a node, the least recently updated child is
+	// evicted to make room for a new one. 0 is no cap. With a cap the tree has at most
+	// MaxChildren^Depth leaves no matter how much data the model sees
+	MaxChildren int
+}
+
+// DefaultCDF16Config returns the default model parameters
+func DefaultCDF16Config() CDF16Config {
+	return CDF16Config{
+		Fixed: CDF16Fixed,
+		Rate:  CDF16Rate,
+		Size:  CDF16Size,
+		Depth: CDF16Depth,
+	}
+}
+
+// Scale is the sum of the cdf
+func (c CDF16Config) Scale() int {
+	return 1 << c.Fixed
+}
+
+// fold maps a symbol to the alphabet, symbols outside of it are folded into its last symbol
+func (c CDF16Config) fold(s int) uint16 {
+	if s < 0 || s >= c.Size {
+		return uint16(c.Size - 1)
+	}
+	return uint16(s)
+}
+
+// Node16 is a context node
+type Node16 struct {
+	Model    []uint16
+	Children Children16
+	// Used is when the node was last updated
+	Used uint64
+}
+
+// NewNode16 creates a new context node with a uniform model
+func NewNode16(cfg CDF16Config) *Node16 {
+	model := make([]uint16, cfg.Size+1)
+	var children Children16 = make(MapChildren16)
+	if cfg.SliceChildren {
+		children = &SliceChildren16{}
+	}
+	for i := range model {
+		model[i] = uint16(i * cfg.Scale() / cfg.Size)
+	}
+	return &Node16{
+		Model:    model,
+		Children: children,
+	}
+}
+
+// Reset re-initializes the node to a uniform model with no children
+func (n *Node16) Reset(cfg CDF16Config) {
+	for i := range n.Model {
+		n.Model[i] = uint16(i * cfg.Scale() / cfg.Size)
+	}
+	n.Children.Clear()
+}
+
+// Clone deep copies the node and all of its descendants
+func (n *Node16) Clone() *Node16 {
+	model := make([]uint16, len(n.Model))
+	copy(model, n.Model)
+	var children Children16 = make(MapChildren16, n.Children.Len())
+	if _, ok := n.Children.(*SliceChildren16); ok {
+		children = &SliceChildren16{}
+	}
+	n.Children.Each(func(s uint16, child *Node16) {
+		children.Set(s, child.Clone())
+	})
+	return &Node16{
+		Model:    model,
+		Children: children,
+		Used:     n.Used,
+	}
+}
+
+// ContextOrder is the direction the context is traversed in the context tree
+type ContextOrder int
+
+const (
+	// OldestFirst traverses the context from the oldest symbol to the most recent, this is the default
+	OldestFirst ContextOrder = iota
+	// NewestFirst traverses the context from the most recent symbol to the oldest
+	NewestFirst
+)
+
+// CDF16 is a context based cumulative distributive function model
+// https://fgiesen.wordpress.com/2015/05/26/models-for-adaptive-arithmetic-coding/
+type CDF16 struct {
+	Config CDF16Config
+	Root   *Node16
+	Mixin  [][]uint16
+	Order  ContextOrder
+	// Clock counts updates for least recently used eviction
+	Clock uint64
+}
+
+// NewCDF16 creates a new CDF16 with the default config
+func NewCDF16() *CDF16 {
+	return NewCDF16WithConfig(DefaultCDF16Config())
+}
+
+// NewCDF16WithConfig creates a new CDF16 with the given config
+func NewCDF16WithConfig(cfg CDF16Config) *CDF16 {
+	root, mixin := NewNode16(cfg), make([][]uint16, cfg.Size)
+
+	for i := range mixin {
+		sum, m := 0, make([]uint16, cfg.Size+1)
+		for j := range m {
+			m[j] = uint16(sum)
+			sum++
+			if j == i {
+				sum += cfg.Scale() - cfg.Size
+			}
+		}
+		mixin[i] = m
+	}
+
+	return &CDF16{
+		Config: cfg,
+		Root:   root,
+		Mixin:  mixin,
+	}
+}
+
+// Reset resets the model to its initial state without allocating a new root, the nodes of the
+// context tree are recycled
+func (c *CDF16) Reset() {
+	c.Root.Children.Each(func(_ uint16, child *Node16) {
+		release(child)
+	})
+	c.Root.Reset(c.Config)
+	c.Root.Used, c.Clock = 0, 0
+}
+
+// Prime sets the root model to the byte frequencies of a representative corpus, every symbol keeps
+// a probability of at least 1/Scale. Bytes outside of the alphabet are ignored. Contexts that haven't
+// been seen fall back to the root model, so the prior mostly helps the first bytes of a stream
+func (c *CDF16) Prime(corpus []byte) {
+	counts, total := make([]int, c.Config.Size), 0
+	for _, b := range corpus {
+		if int(b) < len(counts) {
+			counts[b]++
+			total++
+		}
+	}
+	if total == 0 {
+		return
+	}
+	model, free, sum := c.Root.Model, c.Config.Scale()-c.Config.Size, 0
+	for i, count := range counts {
+		sum += count
+		model[i+1] = uint16(i + 1 + sum*free/total)
+	}
+}
+
+// Clone deep copies the model so that it can be updated independently, the mixin table is
+// never modified and is shared
+func (c *CDF16) Clone() *CDF16 {
+	return &CDF16{
+		Config: c.Config,
+		Root:   c.Root.Clone(),
+		Mixin:  c.Mixin,
+		Order:  c.Order,
+		Clock:  c.Clock,
+	}
+}
+
+// Context16 is a 16 bit context
+type Context16 struct {
+	Context []uint16
+	First   int
+	// filled is the number of symbols in the context, at most len(Context)
+	filled int
+}
+
+// NewContext16 creates a new context, a negative depth is clamped to 0.
+// A depth 0 context holds no symbols so the model is order-0: every lookup uses the root
+func NewContext16(depth int) *Context16 {
+	if depth < 0 {
+		depth = 0
+	}
+	return &Context16{
+		Context: make([]uint16, depth),
+	}
+}
+
+// ResetContext resets the context
+func (c *Context16) ResetContext() {
+	c.First, c.filled = 0, 0
+	for i := range c.Context {
+		c.Context[i] = 0
+	}
+}
+
+// AddContext adds a symbol to the context
+func (c *Context16) AddContext(s uint16) {
+	context, first := c.Context, c.First
+	length := len(context)
+	if length > 0 {
+		context[first], c.First = s, (first+1)%length
+		if c.filled < length {
+			c.filled++
+		}
+	}
+}
+
+// Len is the number of symbols added since the context was reset, at most Depth
+func (c *Context16) Len() int {
+	return c.filled
+}
+
+// Depth is the number of symbols the context holds
+func (c *Context16) Depth() int {
+	return len(c.Context)
+}
+
+// start returns the first context index to traverse, the step to the next one, and the number of
+// symbols to traverse. Only the symbols that have been added are traversed, so a short history
+// uses a shorter path instead of the unfilled slots of the ring buffer
+func (c *CDF16) start(ctxt *Context16) (first, step, filled int) {
+	length := len(ctxt.Context)
+	if c.Order == NewestFirst && length > 0 {
+		return (ctxt.First + length - 1) % length, length - 1, ctxt.filled
+	}
+	if ctxt.filled < length {
+		return 0, 1, ctxt.filled
+	}
+	return ctxt.First, 1, ctxt.filled
+}
+
+// Model gets the model for the current context
+func (c *CDF16) Model(ctxt *Context16) []uint16 {
+	if ctxt.filled == 0 {
+		// order-0: an empty or depth 0 context always uses the root
+		return c.Root.Model
+	}
+	context := ctxt.Context
+	length := len(context)
+	first, step, filled := c.start(ctxt)
+	var lookUp func(n *Node16, current, depth int) *Node16
+	lookUp = func(n *Node16, current, depth int) *Node16 {
+		if depth >= filled {
+			return n
+		}
+
+		node := n.Children.Get(context[current])
+		if node == nil {
+			return n
+		}
+		child := lookUp(node, (current+step)%length, depth+1)
+		if child == nil {
+			return n
+		}
+		return child
+	}
+
+	return lookUp(c.Root, first, 0).Model
+}
+
+// Probability returns the probability of a symbol in the current context without changing the
+// model or the context
+func (c *CDF16) Probability(s uint16, ctxt *Context16) float64 {
+	model := c.Model(ctxt)
+	return float64(model[int(s)+1]-model[s]) / float64(c.Config.Scale())
+}
+
+// Distribution returns the probability of each symbol in the current context
+func (c *CDF16) Distribution(ctxt *Context16) []float64 {
+	model := c.Model(ctxt)
+	distribution := make([]float64, len(model)-1)
+	for i := range distribution {
+		distribution[i] = float64(model[i+1]-model[i]) / float64(c.Config.Scale())
+	}
+	return distribution
+}
+
+// Predict returns the most probable next symbol in the current context and its probability
+func (c *CDF16) Predict(ctxt *Context16) (symbol uint16, prob float64) {
+	for i, p := range c.Distribution(ctxt) {
+		if p > prob {
+			symbol, prob = uint16(i), p
+		}
+	}
+	return symbol, prob
+}
+
+// Update updates the model
+func (c *CDF16) Update(s uint16, ctxt *Context16) {
+	context, mixin, rate := ctxt.Context, c.Mixin[s], uint(c.Config.Rate)
+	c.Clock++
+	if ctxt.filled == 0 {
+		// order-0: an empty or depth 0 context only updates the root
+		adapt(c.Root.Model, mixin, rate)
+		ctxt.AddContext(s)
+		return
+	}
+	length := len(context)
+	first, step, filled := c.start(ctxt)
+	var update func(n *Node16, current, depth int)
+	update = func(n *Node16, current, depth int) {
+		adapt(n.Model, mixin, rate)
+
+		if depth >= filled {
+			return
+		}
+
+		node := n.Children.Get(context[current])
+		if node == nil {
+			if max := c.Config.MaxChildren; max > 0 && n.Children.Len() >= max {
+				c.evict(n)
+			}
+			node = newNode(c.Config)
+			n.Children.Set(context[current], node)
+		}
+		node.Used = c.Clock
+		update(node, (current+step)%length, depth+1)
+	}
+
+	update(c.Root, first, 0)
+	ctxt.AddContext(s)
+}
+
+// adapt moves the model toward the mixin of the symbol by 1/2^rate of the difference
+func adapt(model, mixin []uint16, rate uint) {
+	size := len(model) - 1
+	for i := 1; i < size; i++ {
+		a, b := int(model[i]), int(mixin[i])
+		model[i] = uint16(a + ((b - a) >> rate))
+		if model[i] < model[i-1] {
+			model[i] = model[i-1]
+		}
+	}
+}
+
+// evict removes the least recently updated child of a node
+func (c *CDF16) evict(n *Node16) {
+	var (
+		oldest uint16
+		used   uint64
+		found  bool
+	)
+	n.Children.Each(func(key uint16, child *Node16) {
+		if !found || child.Used < used || (child.Used == used && key < oldest) {
+			oldest, used, found = key, child.Used, true
+		}
+	})
+	if found {
+		child := n.Children.Get(oldest)
+		n.Children.Delete(oldest)
+		release(child)
+	}
+}
+
+// Model is a context model that the complexity is measured with, CDF16 is the default
+type Model interface {
+	// Update updates the model with the symbol and adds it to the context
+	Update(s uint16, ctxt *Context16)
+	// Model gets the cdf for the current context
+	Model(ctxt *Context16) []uint16
+	// Reset resets the model to its initial state
+	Reset()
+}
+
+// Complexity is an entorpy based anomaly detector. Bytes outside of the alphabet of the model are folded
+// into its last symbol
+type Complexity struct {
+	Model Model
+	// Config is the config of the model, its cdfs sum to 1 << Config.Fixed
+	Config CDF16Config
+	depth  int
+	// stream is the context of Observe
+	stream *Context16
+}
+
+// NewComplexity creates a new entorpy based model, a negative depth is clamped to 0.
+// At depth 0 the complexity is that of an order-0 model with no context
+func NewComplexity(depth int) *Complexity {
+	cfg := DefaultCDF16Config()
+	cfg.Depth = depth
+	return NewComplexityWithConfig(cfg)
+}
+
+// NewComplexityWithConfig creates a new entorpy based model with the given config
+func NewComplexityWithConfig(cfg CDF16Config) *Complexity {
+	if cfg.Depth < 0 {
+		cfg.Depth = 0
+	}
+	return NewComplexityWithModel(NewCDF16WithConfig(cfg), cfg)
+}
+
+// NewComplexityWithModel creates a new entorpy based model that measures the complexity with the given
+// model. The model's cdfs must sum to 1 << cfg.Fixed over cfg.Size symbols, the contexts are cfg.Depth deep
+func NewComplexityWithModel(model Model, cfg CDF16Config) *Complexity {
+	if cfg.Depth < 0 {
+		cfg.Depth = 0
+	}
+	return &Complexity{
+		Model:  model,
+		Config: cfg,
+		depth:  cfg.Depth,
+		stream: NewContext16(cfg.Depth),
+	}
+}
+
+// Stats computes statistics about the context tree of the model, they are zero if the model isn't a CDF16
+func (c *Complexity) Stats() ModelStats {
+	if model, ok := c.Model.(*CDF16); ok {
+		return model.Stats()
+	}
+	return ModelStats{}
+}
+
+// Reset resets the model so it can be reused for another input
+func (c *Complexity) Reset() {
+	c.Model.Reset()
+	c.stream.ResetContext()
+}
+
+// Observe outputs the complexity of the next byte of a stream given everything observed before it,
+// and then learns the byte. Unlike Complexity it works in a single pass over an unbounded stream
+func (c *Complexity) Observe(b byte) float32 {
+	s := c.Config.fold(int(b))
+	model := c.Model.Model(c.stream)
+	surprise := float32(c.Config.Fixed + 1 - bits.Len16(model[s+1]-model[s]))
+	c.Model.Update(s, c.stream)
+	return surprise
+}
+
+// Complexity outputs the complexity, the complexity of an empty input is 0
+func (c *Complexity) Complexity(input []byte) float32 {
+	bitsPerByte, _ := c.ComplexityFull(input)
+	return bitsPerByte
+}
+
+// ComplexityFull outputs the complexity and the total number of bits in a single pass
+func (c *Complexity) ComplexityFull(input []byte) (bitsPerByte float32, total uint64) {
+	c.Train(input)
+	return c.score(input)
+}
+
+// Train updates the model with the input without scoring it, the model keeps what it learned from
+// earlier calls
+func (c *Complexity) Train(input []byte) {
+	ctxt := NewContext16(c.depth)
+	for _, b := range input {
+		c.Model.Update(c.Config.fold(int(b)), ctxt)
+	}
+}
+
+// Score outputs the complexity of the input under the model without updating it
+func (c *Complexity) Score(input []byte) float32 {
+	bitsPerByte, _ := c.score(input)
+	return bitsPerByte
+}
+
+func (c *Complexity) score(input []byte) (bitsPerByte float32, total uint64) {
+	if len(input) == 0 {
+		return 0, 0
+	}
+	ctxt := NewContext16(c.depth)
+	for _, b := range input {
+		s := c.Config.fold(int(b))
+		model := c.Model.Model(ctxt)
+		total += uint64(bits.Len16(model[s+1] - model[s]))
+		ctxt.AddContext(s)
+	}
+
+	bitsPerByte = float32(c.Config.Fixed+1) - (float32(total) / float32(len(input)))
+	return bitsPerByte, total
+}
+
+// NormalizedComplexity outputs the complexity over the complexity of an order-0 model with the same
+// config, a ratio below 1 is structure the context found that byte frequencies alone miss. Both
+// models are scored adaptively like Observe: each byte is scored before it is learned, so the
+// context model can't memorize the input. The ratio needs tens of kilobytes to be meaningful: the
+// contexts of short inputs are mostly new, and as new nodes have adapted less than the root they
+// score random bytes about 10% better than the order-0 model. The model of c keeps what it learned
+func (c *Complexity) NormalizedComplexity(input []byte) float32 {
+	if len(input) == 0 {
+		return 1
+	}
+	cfg := c.Config
+	cfg.Depth = 0
+	order0 := NewComplexityWithConfig(cfg).adaptive(input)
+	return c.adaptive(input) / order0
+}
+
+// adaptive outputs the complexity of the input with each byte scored before the model learns it
+func (c *Complexity) adaptive(input []byte) float32 {
+	if len(input) == 0 {
+		return 0
+	}
+	ctxt, total := NewContext16(c.depth), uint64(0)
+	for _, b := range input {
+		s := c.Config.fold(int(b))
+		model := c.Model.Model(ctxt)
+		total += uint64(bits.Len16(model[s+1] - model[s]))
+		c.Model.Update(s, ctxt)
+	}
+	return float32(c.Config.Fixed+1) - (float32(total) / float32(len(input)))
+}
+
+// ComplexityParallel estimates the complexity by splitting the input into chunks that are each
+// scored on a fresh model with the same config in parallel. The chunks don't share statistics, so
+// the estimate is the mean complexity of the chunks weighted by their length: for input that is
+// uniform throughout it is close to Complexity, but structure that repeats across chunks isn't
+// rewarded and the error grows with the number of chunks. The model of c isn't used
+func (c *Complexity) ComplexityParallel(input []byte, chunks int) float32 {
+	if len(input) == 0 {
+		return 0
+	}
+	if chunks > len(input) {
+		chunks = len(input)
+	}
+	if chunks < 1 {
+		chunks = 1
+	}
+	size, totals := (len(input)+chunks-1)/chunks, make([]uint64, chunks)
+	done := make(chan bool, chunks)
+	for i := 0; i < chunks; i++ {
+		go func(i int) {
+			start, end := i*size, (i+1)*size
+			if start > len(input) {
+				start = len(input)
+			}
+			if end > len(input) {
+				end = len(input)
+			}
+			complexity := NewComplexityWithConfig(c.Config)
+			_, totals[i] = complexity.ComplexityFull(input[start:end])
+			done <- true
+		}(i)
+	}
+	var total uint64
+	for i := 0; i < chunks; i++ {
+		<-done
+	}
+	for _, t := range totals {
+		total += t
+	}
+	return float32(c.Config.Fixed+1) - (float32(total) / float32(len(input)))
+}
+
+// WindowedComplexity outputs the complexity of each window of the input, the windows start every step
+// bytes and the model is reset for each one. The last window is shortened to end at the end of the input
+func (c *Complexity) WindowedComplexity(input []byte, window, step int) []float32 {
+	if window <= 0 || step <= 0 || len(input) == 0 {
+		return nil
+	}
+	complexity := make([]float32, 0, (len(input)+step-1)/step)
+	for start := 0; ; start += step {
+		end := start + window
+		if end > len(input) {
+			end = len(input)
+		}
+		c.Reset()
+		complexity = append(complexity, c.Complexity(input[start:end]))
+		if end == len(input) {
+			break
+		}
+	}
+	return complexity
+}
+
+// ComplexityProfile outputs the complexity of each byte of the input after training on the whole
+// input, the mean of the profile is the complexity. Surprising bytes stand out as peaks
+func (c *Complexity) ComplexityProfile(input []byte) []float32 {
+	ctxt := NewContext16(c.depth)
+	for _, b := range input {
+		c.Model.Update(c.Config.fold(int(b)), ctxt)
+	}
+	ctxt.ResetContext()
+
+	profile, fixed := make([]float32, len(input)), c.Config.Fixed+1
+	for i, b := range input {
+		s := c.Config.fold(int(b))
+		model := c.Model.Model(ctxt)
+		profile[i] = float32(fixed - bits.Len16(model[s+1]-model[s]))
+		ctxt.AddContext(s)
+	}
+	return profile
+}
+
+// MaxAlphabet is the largest supported alphabet. The mixin table has alphabet entries of
+// alphabet+1 uint16 each, so model memory grows quadratically: 4096 symbols is about 32MB
+const MaxAlphabet = 4096
+
+// NewComplexityN creates a new entorpy based model over an alphabet of the given size, which is
+// clamped to [1, MaxAlphabet]. Alphabets larger than CDF16Size use a 15 bit cdf so that every
+// symbol keeps room to be learned
+func NewComplexityN(depth, alphabet int) *Complexity {
+	if alphabet < 1 {
+		alphabet = 1
+	} else if alphabet > MaxAlphabet {
+		alphabet = MaxAlphabet
+	}
+	cfg := DefaultCDF16Config()
+	cfg.Depth, cfg.Size = depth, alphabet
+	if alphabet > CDF16Size {
+		cfg.Fixed = 15
+	}
+	return NewComplexityWithConfig(cfg)
+}
+
+// ComplexityRunes outputs the complexity of a rune sequence, with each rune as a single symbol.
+// Runes outside of the alphabet are folded into its last symbol
+func (c *Complexity) ComplexityRunes(input []rune) float32 {
+	if len(input) == 0 {
+		return 0
+	}
+	symbols := make([]uint16, len(input))
+	for i, r := range input {
+		symbols[i] = c.Config.fold(int(r))
+	}
+
+	ctxt := NewContext16(c.depth)
+	for _, s := range symbols {
+		c.Model.Update(s, ctxt)
+	}
+	ctxt.ResetContext()
+
+	var total uint64
+	for _, s := range symbols {
+		model := c.Model.Model(ctxt)
+		total += uint64(bits.Len16(model[int(s)+1] - model[s]))
+		ctxt.AddContext(s)
+	}
+
+	return float32(c.Config.Fixed+1) - (float32(total) / float32(len(input)))
+}
+
+// ModelStats are aggregate statistics about a trained model, ChildrenBytes is an estimate of the
+// memory used to store the children of the nodes
+type ModelStats struct {
+	Nodes           int     `json:"nodes"`
+	AverageChildren float64 `json:"average_children"`
+	MaxChildren     int     `json:"max_children"`
+	Depth           int     `json:"depth"`
+	ModelBytes      int     `json:"model_bytes"`
+	ChildrenBytes   int     `json:"children_bytes"`
+}
+
+// Stats computes statistics about the context tree of the model
+func (c *CDF16) Stats() ModelStats {
+	var stats ModelStats
+	children := 0
+	var walk func(n *Node16, depth int)
+	walk = func(n *Node16, depth int) {
+		stats.Nodes++
+		stats.ModelBytes += 2 * len(n.Model)
+		stats.ChildrenBytes += n.Children.Bytes()
+		children += n.Children.Len()
+		if n.Children.Len() > stats.MaxChildren {
+			stats.MaxChildren = n.Children.Len()
+		}
+		if depth > stats.Depth {
+			stats.Depth = depth
+		}
+		n.Children.Each(func(_ uint16, child *Node16) {
+			walk(child, depth+1)
+		})
+	}
+	walk(c.Root, 0)
+	stats.AverageChildren = float64(children) / float64(stats.Nodes)
+	return stats
+}
@@ -0,0 +1,670 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestComplexityFold(t *testing.T) {
+	input, folded := []byte("hello"), make([]byte, 5)
+	for i := range folded {
+		folded[i] = 15
+	}
+	expected := NewComplexityN(CDF16Depth, 16).Complexity(folded)
+	if c := NewComplexityN(CDF16Depth, 16).Complexity(input); c != expected {
+		t.Fatalf("the complexity is %f, not the %f of the folded input", c, expected)
+	}
+	c := NewComplexityN(CDF16Depth, 16)
+	c.Train(input)
+	c.Score(input)
+	c.ComplexityProfile(input)
+	c.Observe('h')
+	c.Model.(*CDF16).CrossEntropy(input)
+}
+
+func TestNormalizedComplexity(t *testing.T) {
+	text := curie(t, 1<<16)
+	structured := NewComplexity(CDF16Depth).NormalizedComplexity(text)
+	if structured >= .8 {
+		t.Fatalf("the ratio of text is %f", structured)
+	}
+	random := make([]byte, len(text))
+	rand.New(rand.NewSource(1)).Read(random)
+	if ratio := NewComplexity(CDF16Depth).NormalizedComplexity(random); math.Abs(float64(ratio)-1) > .15 {
+		t.Fatalf("the ratio of random bytes is %f", ratio)
+	}
+}
+
+func benchmarkComplexity(b *testing.B, depth int) {
+	input, c := curie(b, 4096), NewComplexity(depth)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Reset()
+		c.Complexity(input)
+	}
+}
+
+func BenchmarkComplexityDepth0(b *testing.B) {
+	benchmarkComplexity(b, 0)
+}
+
+func BenchmarkComplexityDepth1(b *testing.B) {
+	benchmarkComplexity(b, 1)
+}
+
+func BenchmarkComplexityDepth2(b *testing.B) {
+	benchmarkComplexity(b, 2)
+}
+
+func BenchmarkComplexityDepth3(b *testing.B) {
+	benchmarkComplexity(b, 3)
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	input, model := curie(b, 4096), NewCDF16WithConfig(DefaultCDF16Config())
+	ctxt := NewContext16(model.Config.Depth)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		model.Update(uint16(input[i%len(input)]), ctxt)
+	}
+}
+
+func TestMixin(t *testing.T) {
+	small := DefaultCDF16Config()
+	small.Fixed, small.Size = 8, 16
+	for _, cfg := range []CDF16Config{DefaultCDF16Config(), small} {
+		scale, size := cfg.Scale(), cfg.Size
+		for s, m := range NewCDF16WithConfig(cfg).Mixin {
+			if len(m) != size+1 || m[0] != 0 || int(m[size]) != scale {
+				t.Fatalf("mixin %d has %d entries from %d to %d", s, len(m), m[0], m[size])
+			}
+			for i := 1; i < len(m); i++ {
+				step := int(m[i]) - int(m[i-1])
+				switch {
+				case i == s+1 && step != scale-size+1:
+					t.Fatalf("mixin %d steps by %d at its symbol", s, step)
+				case i != s+1 && step != 1:
+					t.Fatalf("mixin %d steps by %d at %d", s, step, i)
+				}
+			}
+		}
+	}
+}
+
+func TestUpdateMonotonic(t *testing.T) {
+	model, rnd := NewCDF16WithConfig(DefaultCDF16Config()), rand.New(rand.NewSource(1))
+	ctxt := NewContext16(CDF16Depth)
+	s := uint16(0)
+	for i := 0; i < 1000000; i++ {
+		// long runs of the extreme symbols push the cdf the hardest
+		if rnd.Intn(64) == 0 {
+			s = uint16(rnd.Intn(2) * (CDF16Size - 1))
+			if rnd.Intn(4) == 0 {
+				s = uint16(rnd.Intn(CDF16Size))
+			}
+		}
+		model.Update(s, ctxt)
+		root := model.Root.Model
+		for j := 1; j < len(root); j++ {
+			if root[j] < root[j-1] {
+				t.Fatalf("the root model decreases at %d after %d updates", j, i+1)
+			}
+		}
+	}
+}
+
+// height is the length of the longest path from the node to a leaf
+func height(n *Node16) int {
+	max := 0
+	n.Children.Each(func(_ uint16, child *Node16) {
+		if h := height(child) + 1; h > max {
+			max = h
+		}
+	})
+	return max
+}
+
+func TestShortHistory(t *testing.T) {
+	cfg := DefaultCDF16Config()
+	cfg.Depth = 3
+	model, ctxt := NewCDF16WithConfig(cfg), NewContext16(cfg.Depth)
+	for i, s := range []uint16{'a', 'b', 'c', 'd', 'e'} {
+		expected := i
+		if expected > cfg.Depth {
+			expected = cfg.Depth
+		}
+		if ctxt.Len() != expected {
+			t.Fatalf("the context has %d symbols after %d updates", ctxt.Len(), i)
+		}
+		model.Update(s, ctxt)
+		if h := height(model.Root); h != expected {
+			t.Fatalf("the tree is %d deep after a history of %d symbols", h, i)
+		}
+	}
+
+	// a history of one symbol looks up the node of that symbol, not a path through unfilled slots
+	ctxt.ResetContext()
+	ctxt.AddContext('a')
+	if node := model.Root.Children.Get('a'); &model.Model(ctxt)[0] != &node.Model[0] {
+		t.Fatal("the model of a one symbol history isn't the depth 1 node")
+	}
+}
+
+func TestRate(t *testing.T) {
+	input := curie(t, 1024)
+	slow, fast := DefaultCDF16Config(), DefaultCDF16Config()
+	slow.Rate, fast.Rate = 7, 3
+	a := NewComplexityWithConfig(slow).Complexity(input)
+	b := NewComplexityWithConfig(fast).Complexity(input)
+	if a == b {
+		t.Fatalf("rates %d and %d give the same complexity %f", slow.Rate, fast.Rate, a)
+	}
+}
+
+func BenchmarkComplexityNew(b *testing.B) {
+	input := curie(b, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewComplexity(CDF16Depth).Complexity(input)
+	}
+}
+
+func BenchmarkComplexityReset(b *testing.B) {
+	input, c := curie(b, 1024), NewComplexity(CDF16Depth)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Reset()
+		c.Complexity(input)
+	}
+}
+
+func TestComplexityFull(t *testing.T) {
+	input := curie(t, 1024)
+	bitsPerByte, total := NewComplexity(CDF16Depth).ComplexityFull(input)
+	if expected := float32(CDF16Fixed+1) - float32(total)/float32(len(input)); bitsPerByte != expected {
+		t.Fatalf("the complexity is %f but the total gives %f", bitsPerByte, expected)
+	}
+	if c := NewComplexity(CDF16Depth).Complexity(input); c != bitsPerByte {
+		t.Fatalf("the complexity is %f, not %f", c, bitsPerByte)
+	}
+}
+
+// deepest returns the deepest node of the path last visited by Update, nodes on the path were used
+// at the clock
+func deepest(n *Node16, clock uint64) *Node16 {
+	var found *Node16
+	n.Children.Each(func(_ uint16, child *Node16) {
+		if child.Used == clock {
+			found = deepest(child, clock)
+		}
+	})
+	if found == nil {
+		return n
+	}
+	return found
+}
+
+func TestContextOrder(t *testing.T) {
+	input := bytes.Repeat([]byte("abc"), 32)
+	models := make([]*CDF16, 2)
+	for i, order := range []ContextOrder{OldestFirst, NewestFirst} {
+		model, ctxt := NewCDF16WithConfig(DefaultCDF16Config()), NewContext16(CDF16Depth)
+		model.Order = order
+		for _, b := range input {
+			snapshot := Context16{Context: append([]uint16{}, ctxt.Context...), First: ctxt.First, filled: ctxt.filled}
+			model.Update(uint16(b), ctxt)
+			// the model looked up for the context is the one the update adapted
+			if &model.Model(&snapshot)[0] != &deepest(model.Root, model.Clock).Model[0] {
+				t.Fatalf("the model and the update of order %d use different nodes", order)
+			}
+		}
+		models[i] = model
+	}
+
+	// the context "ab" is the path a, b oldest first and b, a newest first
+	path := func(model *CDF16, a, b uint16) bool {
+		node := model.Root.Children.Get(a)
+		return node != nil && node.Children.Get(b) != nil
+	}
+	if !path(models[0], 'a', 'b') || path(models[0], 'b', 'a') {
+		t.Fatal("the oldest first tree doesn't have the path a, b")
+	}
+	if !path(models[1], 'b', 'a') || path(models[1], 'a', 'b') {
+		t.Fatal("the newest first tree doesn't have the path b, a")
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	c := NewComplexity(CDF16Depth)
+	if complexity := c.Complexity(nil); complexity != 0 {
+		t.Fatalf("the complexity of an empty input is %f", complexity)
+	}
+	if complexity := c.ComplexityParallel(nil, 4); complexity != 0 {
+		t.Fatalf("the parallel complexity of an empty input is %f", complexity)
+	}
+	if complexity := c.ComplexityRunes(nil); complexity != 0 {
+		t.Fatalf("the complexity of empty runes is %f", complexity)
+	}
+	if ratio := c.NormalizedComplexity(nil); ratio != 1 {
+		t.Fatalf("the normalized complexity of an empty input is %f", ratio)
+	}
+}
+
+func TestDepth(t *testing.T) {
+	input := curie(t, 1024)
+	order0 := NewComplexity(0)
+	expected := order0.Complexity(input)
+	if n := order0.Model.(*CDF16).Root.Children.Len(); n != 0 {
+		t.Fatalf("the order-0 model has %d contexts", n)
+	}
+	// every lookup of an order-0 model uses the root
+	model, ctxt := order0.Model.(*CDF16), NewContext16(0)
+	for _, b := range input[:16] {
+		ctxt.AddContext(uint16(b))
+		if &model.Model(ctxt)[0] != &model.Root.Model[0] {
+			t.Fatal("the order-0 model looks up a context")
+		}
+	}
+	// a negative depth is clamped to order-0
+	if c := NewComplexity(-1).Complexity(input); c != expected {
+		t.Fatalf("the complexity at depth -1 is %f, not %f", c, expected)
+	}
+	if ctxt := NewContext16(-1); ctxt.Depth() != 0 {
+		t.Fatalf("the context of depth -1 has depth %d", ctxt.Depth())
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := NewComplexity(2)
+	c.Train([]byte("abab"))
+	// the nodes are the root, a, b, b after a and a after b
+	expected := ModelStats{
+		Nodes:           5,
+		AverageChildren: .8,
+		MaxChildren:     2,
+		Depth:           2,
+		ModelBytes:      5 * 2 * (CDF16Size + 1),
+	}
+	stats := c.Stats()
+	expected.ChildrenBytes = stats.ChildrenBytes
+	if stats != expected {
+		t.Fatalf("the stats are %+v, not %+v", stats, expected)
+	}
+	if stats.ChildrenBytes <= 0 {
+		t.Fatalf("the children use %d bytes", stats.ChildrenBytes)
+	}
+}
+
+func TestPredict(t *testing.T) {
+	model, ctxt := NewCDF16(), NewContext16(CDF16Depth)
+	cycle := []uint16{3, 1, 4, 1, 5}
+	for i := 0; i < 200; i++ {
+		model.Update(cycle[i%len(cycle)], ctxt)
+	}
+	// the history is a whole number of cycles so the next symbol starts the cycle again
+	for i := 0; i < 2*len(cycle); i++ {
+		expected := cycle[i%len(cycle)]
+		symbol, prob := model.Predict(ctxt)
+		if symbol != expected || prob < .5 {
+			t.Fatalf("the prediction at %d is %d with probability %f, not %d", i, symbol, prob, expected)
+		}
+		if p := model.Probability(symbol, ctxt); p != prob {
+			t.Fatalf("the prediction has probability %f, not %f", prob, p)
+		}
+		ctxt.AddContext(expected)
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	model := NewCDF16WithConfig(DefaultCDF16Config())
+	NewComplexityWithModel(model, model.Config).Train(curie(t, 1024))
+	ctxt, clock := NewContext16(model.Config.Depth), model.Clock
+	for _, s := range []uint16{1, 2, 3} {
+		ctxt.AddContext(s)
+		root := append([]uint16{}, model.Root.Model...)
+		first, filled := ctxt.First, ctxt.Len()
+		distribution, sum := model.Distribution(ctxt), 0.0
+		if len(distribution) != model.Config.Size {
+			t.Fatalf("the distribution has %d symbols, not %d", len(distribution), model.Config.Size)
+		}
+		for i, p := range distribution {
+			if p != model.Probability(uint16(i), ctxt) {
+				t.Fatalf("the distribution of %d is %f, not its probability", i, p)
+			}
+			sum += p
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Fatalf("the distribution sums to %f", sum)
+		}
+		if model.Clock != clock || !reflect.DeepEqual(root, model.Root.Model) {
+			t.Fatal("the distribution updates the model")
+		}
+		if ctxt.First != first || ctxt.Len() != filled {
+			t.Fatal("the distribution changes the context")
+		}
+	}
+}
+
+func TestMaxChildren(t *testing.T) {
+	random := make([]byte, 1<<16)
+	rand.New(rand.NewSource(1)).Read(random)
+	for _, max := range []int{2, 4, 16} {
+		cfg := DefaultCDF16Config()
+		cfg.MaxChildren = max
+		bound, level := 0, 1
+		for depth := 0; depth <= cfg.Depth; depth++ {
+			bound, level = bound+level, level*max
+		}
+		c := NewComplexityWithConfig(cfg)
+		for _, length := range []int{1 << 12, 1 << 16} {
+			c.Reset()
+			complexity := c.Complexity(random[:length])
+			stats := c.Stats()
+			if stats.Nodes > bound || stats.MaxChildren > max {
+				t.Fatalf("a cap of %d children has %d nodes with up to %d children, the bound is %d nodes",
+					max, stats.Nodes, stats.MaxChildren, bound)
+			}
+			// the evicted contexts fall back to shallower ones so the complexity stays in range
+			if math.IsNaN(float64(complexity)) || complexity <= 0 || complexity > float32(cfg.Fixed+1) {
+				t.Fatalf("the complexity with a cap of %d children is %f", max, complexity)
+			}
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	input := curie(t, 2048)
+	model := NewCDF16()
+	NewComplexityWithModel(model, model.Config).Train(input[:1024])
+	snapshot := func(c *CDF16) []byte {
+		var buffer bytes.Buffer
+		if _, err := c.WriteTo(&buffer); err != nil {
+			t.Fatal(err)
+		}
+		return buffer.Bytes()
+	}
+	original := snapshot(model)
+	clone := model.Clone()
+	if !bytes.Equal(snapshot(clone), original) {
+		t.Fatal("the clone isn't the same as the model")
+	}
+	if a, b := clone.CrossEntropy(input[1024:]), model.CrossEntropy(input[1024:]); a != b {
+		t.Fatalf("the clone codes the input in %f bits, not %f", a, b)
+	}
+
+	NewComplexityWithModel(clone, clone.Config).Train(input[1024:])
+	if !bytes.Equal(snapshot(model), original) {
+		t.Fatal("training the clone changes the model")
+	}
+	if bytes.Equal(snapshot(clone), original) {
+		t.Fatal("training the clone doesn't change it")
+	}
+}
+
+func TestComplexityProfile(t *testing.T) {
+	const start, end = 2048, 2048 + 256
+	input := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 100)
+	rand.New(rand.NewSource(1)).Read(input[start:end])
+	profile := NewComplexity(CDF16Depth).ComplexityProfile(input)
+	if len(profile) != len(input) {
+		t.Fatalf("the profile has %d bytes, not %d", len(profile), len(input))
+	}
+	mean := func(profile []float32) float64 {
+		sum := 0.0
+		for _, bits := range profile {
+			sum += float64(bits)
+		}
+		return sum / float64(len(profile))
+	}
+	// the first bytes after the noise have a context of noise, so they are left out of the text
+	text := append(append([]float32{}, profile[:start]...), profile[end+CDF16Depth:]...)
+	if noise := mean(profile[start:end]); noise < 2*mean(text) {
+		t.Fatalf("the noise is %f bits per byte and the text is %f", noise, mean(text))
+	}
+	if complexity := NewComplexity(CDF16Depth).Complexity(input); math.Abs(mean(profile)-float64(complexity)) > 1e-3 {
+		t.Fatalf("the mean of the profile is %f, not the complexity %f", mean(profile), complexity)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	const block = 512
+	c := NewComplexity(CDF16Depth)
+	stream := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 100)
+	means := make([]float64, 0, len(stream)/block)
+	for i := 0; i+block <= len(stream); i += block {
+		sum := 0.0
+		for _, b := range stream[i : i+block] {
+			sum += float64(c.Observe(b))
+		}
+		means = append(means, sum/block)
+	}
+	// once the model has learned the stream the surprise is at its floor, up to rounding
+	for i := 1; i < len(means); i++ {
+		if means[i] > means[i-1]+.01 {
+			t.Fatalf("the surprise rises from %f to %f in block %d", means[i-1], means[i], i)
+		}
+	}
+	if first, last := means[0], means[len(means)-1]; last > first/2 {
+		t.Fatalf("the surprise only falls from %f to %f", first, last)
+	}
+
+	// a reset forgets the stream
+	c.Reset()
+	if surprise := c.Observe(stream[0]); float64(surprise) < means[len(means)-1] {
+		t.Fatalf("the surprise after a reset is %f", surprise)
+	}
+}
+
+func TestWindowedComplexity(t *testing.T) {
+	const window, start, end = 512, 4096, 4096 + 1024
+	input := append([]byte{}, curie(t, 8192+100)...)
+	rand.New(rand.NewSource(1)).Read(input[start:end])
+	c := NewComplexity(CDF16Depth)
+	windows := c.WindowedComplexity(input, window, window)
+	// the last window is the 100 bytes past the last full window
+	if expected := len(input)/window + 1; len(windows) != expected {
+		t.Fatalf("there are %d windows, not %d", len(windows), expected)
+	}
+	if last := NewComplexity(CDF16Depth).Complexity(input[len(input)-100:]); windows[len(windows)-1] != last {
+		t.Fatalf("the last window is %f, not %f", windows[len(windows)-1], last)
+	}
+	text := float32(0)
+	for i, complexity := range windows {
+		if i*window < start || i*window >= end {
+			if complexity > text {
+				text = complexity
+			}
+		}
+	}
+	for i := start / window; i < end/window; i++ {
+		if windows[i] <= text {
+			t.Fatalf("the noise in window %d is %f, the text is up to %f", i, windows[i], text)
+		}
+	}
+	if windows := c.WindowedComplexity(nil, window, window); windows != nil {
+		t.Fatalf("the windows of an empty input are %v", windows)
+	}
+}
+
+func TestComplexityParallel(t *testing.T) {
+	input := curie(t, 1024)
+	exact := NewComplexity(CDF16Depth).Complexity(input)
+	if estimate := NewComplexity(CDF16Depth).ComplexityParallel(input, 1); estimate != exact {
+		t.Fatalf("a single chunk estimates %f, not %f", estimate, exact)
+	}
+	// the chunks don't share statistics so the error grows with the number of chunks
+	previous := exact
+	for _, chunks := range []int{2, 4, 8} {
+		estimate := NewComplexity(CDF16Depth).ComplexityParallel(input, chunks)
+		bound := .15 * math.Log2(float64(chunks))
+		if estimate < previous || float64(estimate-exact) > bound {
+			t.Fatalf("%d chunks estimate %f, the complexity is %f and the bound is %f", chunks, estimate, exact, bound)
+		}
+		previous = estimate
+	}
+}
+
+func TestTrainScore(t *testing.T) {
+	input := curie(t, 2048)
+	a, b := input[:1024], input[1024:]
+	combined := NewComplexity(CDF16Depth).Complexity(b)
+	c := NewComplexity(CDF16Depth)
+	c.Train(b)
+	if separate := c.Score(b); separate != combined {
+		t.Fatalf("training and then scoring is %f, not the %f of Complexity", separate, combined)
+	}
+
+	// a model trained across inputs scores differently than one trained on the input alone
+	c = NewComplexity(CDF16Depth)
+	c.Train(a)
+	c.Train(b)
+	shared := c.Score(b)
+	if shared == combined {
+		t.Fatalf("the model trained on both inputs scores %f, the same as the model trained on one", shared)
+	}
+	if again := c.Score(b); again != shared {
+		t.Fatalf("scoring twice is %f and then %f", shared, again)
+	}
+	// a model that hasn't seen the input scores it as more complex
+	c = NewComplexity(CDF16Depth)
+	c.Train(a)
+	if unseen := c.Score(b); unseen <= combined {
+		t.Fatalf("the model trained on other text scores %f, the model trained on the input %f", unseen, combined)
+	}
+}
+
+// uniform is a stub model that predicts every symbol with the same probability
+type uniform struct {
+	cdf     []uint16
+	updates int
+}
+
+func (u *uniform) Update(s uint16, ctxt *Context16) {
+	u.updates++
+	ctxt.AddContext(s)
+}
+
+func (u *uniform) Model(ctxt *Context16) []uint16 {
+	return u.cdf
+}
+
+func (u *uniform) Reset() {
+	u.updates = 0
+}
+
+func TestModelInterface(t *testing.T) {
+	cfg := DefaultCDF16Config()
+	model := &uniform{cdf: make([]uint16, cfg.Size+1)}
+	for i := range model.cdf {
+		model.cdf[i] = uint16(i * cfg.Scale() / cfg.Size)
+	}
+	c := NewComplexityWithModel(model, cfg)
+	input := curie(t, 1024)
+	// a uniform model over bytes needs 8 bits for every byte
+	if complexity := c.Complexity(input); complexity != 8 {
+		t.Fatalf("the complexity under a uniform model is %f", complexity)
+	}
+	if model.updates != len(input) {
+		t.Fatalf("the model is updated %d times for %d bytes", model.updates, len(input))
+	}
+	if surprise := c.Observe('a'); surprise != 8 || model.updates != len(input)+1 {
+		t.Fatalf("the surprise under a uniform model is %f", surprise)
+	}
+	if c.Reset(); model.updates != 0 {
+		t.Fatal("the model isn't reset")
+	}
+	if stats := c.Stats(); stats != (ModelStats{}) {
+		t.Fatalf("the stats of the stub model are %+v", stats)
+	}
+}
+
+func TestStatsTraversal(t *testing.T) {
+	input := curie(t, 1024)
+	c := NewComplexity(CDF16Depth)
+	c.Train(input)
+	// each node is a distinct path of context symbols from the oldest, plus the root
+	paths := map[string]bool{"": true}
+	for i := range input {
+		filled := i
+		if filled > CDF16Depth {
+			filled = CDF16Depth
+		}
+		for k := 1; k <= filled; k++ {
+			paths[string(input[i-filled:i-filled+k])] = true
+		}
+	}
+	stats := c.Stats()
+	if stats.Nodes != len(paths) {
+		t.Fatalf("the stats count %d nodes, the traversal %d", stats.Nodes, len(paths))
+	}
+	if stats.ModelBytes != 2*(CDF16Size+1)*len(paths) {
+		t.Fatalf("the models use %d bytes for %d nodes", stats.ModelBytes, len(paths))
+	}
+}
+
+func TestPrime(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	// the bytes are drawn from a known skewed distribution over four symbols
+	sample := func(n int) []byte {
+		output := make([]byte, n)
+		for i := range output {
+			switch r := rnd.Float64(); {
+			case r < .5:
+				output[i] = 'a'
+			case r < .75:
+				output[i] = 'b'
+			case r < .9:
+				output[i] = 'c'
+			default:
+				output[i] = 'd'
+			}
+		}
+		return output
+	}
+	corpus, input := sample(4096), sample(32)
+	primed, unprimed := NewCDF16(), NewCDF16()
+	primed.Prime(corpus)
+	a := NewComplexityWithModel(primed, primed.Config).adaptive(input)
+	b := NewComplexityWithModel(unprimed, unprimed.Config).adaptive(input)
+	if a >= b {
+		t.Fatalf("the primed model codes the input in %f bits per byte, the unprimed model in %f", a, b)
+	}
+	// the order-0 entropy of the distribution is about 1.74 bits, which the prior alone comes close to
+	prior := NewCDF16()
+	prior.Prime(corpus)
+	if bits := prior.CrossEntropy(input) / float64(len(input)); bits > 2.5 {
+		t.Fatalf("the prior codes the input in %f bits per byte", bits)
+	}
+}
+
+func TestContextLen(t *testing.T) {
+	ctxt := NewContext16(3)
+	for i := 0; i < 10; i++ {
+		expected := i
+		if expected > 3 {
+			expected = 3
+		}
+		if ctxt.Len() != expected || ctxt.Depth() != 3 {
+			t.Fatalf("the context has %d symbols and depth %d after %d symbols", ctxt.Len(), ctxt.Depth(), i)
+		}
+		ctxt.AddContext(uint16(i))
+	}
+	if ctxt.ResetContext(); ctxt.Len() != 0 {
+		t.Fatalf("the reset context has %d symbols", ctxt.Len())
+	}
+	empty := NewContext16(0)
+	if empty.AddContext(1); empty.Len() != 0 || empty.Depth() != 0 {
+		t.Fatalf("the depth 0 context has %d symbols and depth %d", empty.Len(), empty.Depth())
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"math"
+)
+
+// CrossEntropy outputs the number of bits needed to code the input with the model, the sum of
+// -log2 P(symbol|context). The model isn't trained on the input. Bytes outside of the alphabet are folded
+// into its last symbol
+func (c *CDF16) CrossEntropy(input []byte) float64 {
+	ctxt, minimum, total := NewContext16(c.Config.Depth), 1/float64(c.Config.Scale()), 0.0
+	for _, b := range input {
+		s := c.Config.fold(int(b))
+		p := c.Probability(s, ctxt)
+		if p < minimum {
+			p = minimum
+		}
+		total -= math.Log2(p)
+		ctxt.AddContext(s)
+	}
+	return total
+}
+
+// KLDivergence estimates the Kullback-Leibler divergence of q from p in bits per byte, with the probe
+// taken to be a sample of p
+func KLDivergence(p, q *CDF16, probe []byte) float64 {
+	if len(probe) == 0 {
+		return 0
+	}
+	return (q.CrossEntropy(probe) - p.CrossEntropy(probe)) / float64(len(probe))
+}
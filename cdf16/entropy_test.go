@@ -0,0 +1,68 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func TestCrossEntropy(t *testing.T) {
+	a := curie(t, 4096)
+	b, err := ioutil.ReadFile("complexity.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = b[:len(a)]
+	train := func(input []byte) *CDF16 {
+		model := NewCDF16()
+		NewComplexityWithModel(model, model.Config).Train(input)
+		return model
+	}
+	p, q := train(a), train(b)
+	if ca, cb := p.CrossEntropy(a), p.CrossEntropy(b); ca >= cb {
+		t.Fatalf("the model of text A codes A in %f bits and unrelated text B in %f bits", ca, cb)
+	}
+	if divergence := KLDivergence(p, q, a); divergence <= 0 {
+		t.Fatalf("the divergence of the model of B from the model of A is %f", divergence)
+	}
+	if divergence := KLDivergence(p, p.Clone(), a); divergence != 0 {
+		t.Fatalf("the divergence of a model from itself is %f", divergence)
+	}
+	if divergence := KLDivergence(p, q, nil); divergence != 0 {
+		t.Fatalf("the divergence on an empty probe is %f", divergence)
+	}
+}
+
+func TestCrossEntropyOrder0(t *testing.T) {
+	cfg := DefaultCDF16Config()
+	cfg.Depth = 0
+	model := NewCDF16WithConfig(cfg)
+	// the fresh model is uniform over 256 symbols
+	if bits := model.CrossEntropy([]byte("abc")); bits != 24 {
+		t.Fatalf("the uniform model codes 3 bytes in %f bits", bits)
+	}
+	if bits := model.CrossEntropy(nil); bits != 0 {
+		t.Fatalf("the empty input is coded in %f bits", bits)
+	}
+
+	// primed with 2 a and 1 b, the symbols share the scale beyond the minimum of 1 for every symbol
+	model.Prime([]byte("aab"))
+	scale, free := float64(cfg.Scale()), float64(cfg.Scale()-cfg.Size)
+	a, b := (1+math.Floor(2*free/3))/scale, (1+free-math.Floor(2*free/3))/scale
+	expected := -math.Log2(a) - 2*math.Log2(b)
+	if bits := model.CrossEntropy([]byte("abb")); math.Abs(bits-expected) > 1e-9 {
+		t.Fatalf("the primed model codes the input in %f bits, not %f", bits, expected)
+	}
+	// the empty context of an order-0 model is the root
+	ctxt := NewContext16(0)
+	if p := model.Probability('a', ctxt); p != a {
+		t.Fatalf("the probability of a in the empty context is %f, not %f", p, a)
+	}
+	if c := NewComplexityWithConfig(cfg).Complexity(nil); c != 0 {
+		t.Fatalf("the order-0 complexity of the empty input is %f", c)
+	}
+}
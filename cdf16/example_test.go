@@ -0,0 +1,21 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16_test
+
+import (
+	"fmt"
+
+	"github.com/pointlander/token/cdf16"
+)
+
+func ExampleComplexity_Complexity() {
+	complexity := cdf16.NewComplexity(cdf16.CDF16Depth)
+	text := complexity.Complexity([]byte("the cat sat on the mat, the cat sat on the hat"))
+	complexity.Reset()
+	random := complexity.Complexity([]byte("q8#Lz0vW!pK2&xR9mT$e7jY@uB4nH^cF6gD*sA1oI3lZ5"))
+	fmt.Printf("text %.2f bits per byte, random %.2f bits per byte\n", text, random)
+	// Output:
+	// text 4.35 bits per byte, random 5.04 bits per byte
+}
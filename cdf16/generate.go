@@ -0,0 +1,29 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"math/rand"
+)
+
+// Generate samples n bytes from the model starting from an empty context, the model is only read.
+// The model should have a byte sized alphabet
+func (c *CDF16) Generate(rng *rand.Rand, n int) []byte {
+	ctxt, output := NewContext16(c.Config.Depth), make([]byte, 0, n)
+	for i := 0; i < n; i++ {
+		distribution := c.Distribution(ctxt)
+		sample, sum, symbol := rng.Float64(), 0.0, len(distribution)-1
+		for j, p := range distribution {
+			sum += p
+			if sample < sum {
+				symbol = j
+				break
+			}
+		}
+		output = append(output, byte(symbol))
+		ctxt.AddContext(uint16(symbol))
+	}
+	return output
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	input := curie(t, 1024)
+	seen := make(map[byte]bool)
+	for _, b := range input {
+		seen[b] = true
+	}
+	for _, size := range []int{CDF16Size, 128} {
+		cfg := DefaultCDF16Config()
+		cfg.Size = size
+		model := NewCDF16WithConfig(cfg)
+		NewComplexityWithModel(model, cfg).Train(input)
+		clock := model.Clock
+		output := model.Generate(rand.New(rand.NewSource(1)), 256)
+		if len(output) != 256 {
+			t.Fatalf("%d bytes are generated, not 256", len(output))
+		}
+		if model.Clock != clock {
+			t.Fatal("generating updates the model")
+		}
+		if again := model.Generate(rand.New(rand.NewSource(1)), 256); !bytes.Equal(output, again) {
+			t.Fatal("the same seed generates different output")
+		}
+		unseen, alphabet := 0, 0
+		for b := range seen {
+			if int(b) < size {
+				alphabet++
+			}
+		}
+		for _, b := range output {
+			if int(b) >= size {
+				t.Fatalf("the byte %d is outside of an alphabet of %d", b, size)
+			}
+			if !seen[b] {
+				unseen++
+			}
+		}
+		// the model generates far fewer unseen bytes than sampling the alphabet uniformly would
+		uniform := float64(size-alphabet) / float64(size)
+		if fraction := float64(unseen) / float64(len(output)); fraction > uniform*2/3 {
+			t.Fatalf("%f of the generated bytes aren't in the training input, uniformly it is %f", fraction, uniform)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"math"
+)
+
+// MixedDecay is how much of the past performance of a model is remembered by the mixing weights, lower
+// values switch faster to the model that is currently predicting best
+const MixedDecay = .98
+
+// MixedModel blends the predictions of CDF16 models with context depths 0 to a maximum depth. Each model
+// is weighted by how well it predicted the recent symbols, a Bayesian mixture with exponential forgetting
+type MixedModel struct {
+	Models []*CDF16
+	// Weights are the log2 mixing weights of the models
+	Weights  []float64
+	contexts []*Context16
+	mixed    []uint16
+	blend    []float64
+	weights  []float64
+}
+
+// NewMixedModel creates a mixed model of depths 0 to cfg.Depth with the given config
+func NewMixedModel(cfg CDF16Config) *MixedModel {
+	if cfg.Depth < 0 {
+		cfg.Depth = 0
+	}
+	m := MixedModel{
+		Models:   make([]*CDF16, cfg.Depth+1),
+		Weights:  make([]float64, cfg.Depth+1),
+		contexts: make([]*Context16, cfg.Depth+1),
+		mixed:    make([]uint16, cfg.Size+1),
+		blend:    make([]float64, cfg.Size+1),
+		weights:  make([]float64, cfg.Depth+1),
+	}
+	for depth := range m.Models {
+		config := cfg
+		config.Depth = depth
+		m.Models[depth], m.contexts[depth] = NewCDF16WithConfig(config), NewContext16(depth)
+	}
+	return &m
+}
+
+// Reset resets the models and the mixing weights
+func (m *MixedModel) Reset() {
+	for i, model := range m.Models {
+		model.Reset()
+		m.Weights[i] = 0
+	}
+}
+
+// tail sets dst to the most recent symbols of the context that fit in dst
+func (c *Context16) tail(dst *Context16) {
+	dst.ResetContext()
+	length := c.Depth()
+	n := dst.Depth()
+	if n > c.Len() {
+		n = c.Len()
+	}
+	for k := n - 1; k >= 0; k-- {
+		dst.AddContext(c.Context[(c.First-1-k+2*length)%length])
+	}
+}
+
+// Model gets the blended model for the current context, which must be at least as deep as the deepest
+// model. The returned cdf is reused by the next call
+func (m *MixedModel) Model(ctxt *Context16) []uint16 {
+	max := math.Inf(-1)
+	for _, w := range m.Weights {
+		max = math.Max(max, w)
+	}
+	sum := 0.0
+	for i, w := range m.Weights {
+		m.weights[i] = math.Exp2(w - max)
+		sum += m.weights[i]
+	}
+
+	size, scale, blend := len(m.mixed)-1, m.Models[0].Config.Scale(), m.blend
+	for i := range blend {
+		blend[i] = 0
+	}
+	for d, model := range m.Models {
+		ctxt.tail(m.contexts[d])
+		cdf, weight := model.Model(m.contexts[d]), m.weights[d]/sum
+		for i := 1; i < size; i++ {
+			blend[i] += weight * float64(cdf[i])
+		}
+	}
+	m.mixed[0], m.mixed[size] = 0, uint16(scale)
+	for i := 1; i < size; i++ {
+		m.mixed[i] = uint16(math.Round(blend[i]))
+		if m.mixed[i] < m.mixed[i-1] {
+			m.mixed[i] = m.mixed[i-1]
+		}
+	}
+	return m.mixed
+}
+
+// Update updates the models and their mixing weights with the symbol, and then adds it to the context
+func (m *MixedModel) Update(s uint16, ctxt *Context16) {
+	minimum := 1 / float64(m.Models[0].Config.Scale())
+	for d, model := range m.Models {
+		ctxt.tail(m.contexts[d])
+		p := model.Probability(s, m.contexts[d])
+		if p < minimum {
+			p = minimum
+		}
+		m.Weights[d] = MixedDecay*m.Weights[d] + math.Log2(p)
+		model.Update(s, m.contexts[d])
+	}
+	ctxt.AddContext(s)
+}
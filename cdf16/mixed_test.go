@@ -0,0 +1,22 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"testing"
+)
+
+func TestMixedModel(t *testing.T) {
+	input, cfg := curie(t, 1024), DefaultCDF16Config()
+	cfg.Depth = 3
+	// each byte is scored before the models learn it, so this is the cost of coding the input
+	mixed := NewComplexityWithModel(NewMixedModel(cfg), cfg).adaptive(input)
+	for depth := 0; depth <= cfg.Depth; depth++ {
+		if single := NewComplexity(depth).adaptive(input); mixed >= single {
+			t.Fatalf("the mixed model codes the input in %f bits per byte, the model of depth %d in %f",
+				mixed, depth, single)
+		}
+	}
+}
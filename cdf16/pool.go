@@ -0,0 +1,44 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"sync"
+)
+
+// nodes recycles the context nodes of discarded subtrees
+var nodes sync.Pool
+
+// newNode gets a context node with a uniform model from the pool, or creates one if the pool has
+// none that fits the config
+func newNode(cfg CDF16Config) *Node16 {
+	if n, ok := nodes.Get().(*Node16); ok && len(n.Model) == cfg.Size+1 {
+		if _, slice := n.Children.(*SliceChildren16); slice == cfg.SliceChildren {
+			n.Reset(cfg)
+			n.Used = 0
+			return n
+		}
+	}
+	return NewNode16(cfg)
+}
+
+// release returns a node and its whole subtree to the pool, nothing may reference them afterwards
+func release(n *Node16) {
+	n.Children.Each(func(_ uint16, child *Node16) {
+		release(child)
+	})
+	n.Children.Clear()
+	nodes.Put(n)
+}
+
+// DrainNodes drops the pooled nodes so that they can be garbage collected, and returns how many
+// there were
+func DrainNodes() int {
+	count := 0
+	for nodes.Get() != nil {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	input := curie(t, 1024)
+	model := NewCDF16WithConfig(DefaultCDF16Config())
+	c := NewComplexityWithModel(model, model.Config)
+	expected := c.Complexity(input)
+	// the recycled nodes must be as good as new
+	c.Reset()
+	if complexity := c.Complexity(input); complexity != expected {
+		t.Fatalf("the complexity with recycled nodes is %f, not %f", complexity, expected)
+	}
+}
+
+func TestDrainNodes(t *testing.T) {
+	input := curie(t, 1024)
+	model := NewCDF16WithConfig(DefaultCDF16Config())
+	c := NewComplexityWithModel(model, model.Config)
+	expected := c.Complexity(input)
+	c.Reset()
+	DrainNodes()
+	if count := DrainNodes(); count != 0 {
+		t.Fatalf("%d nodes are left in the pool", count)
+	}
+	// the model allocates new nodes once the pool is empty
+	if complexity := c.Complexity(input); complexity != expected {
+		t.Fatalf("the complexity with a drained pool is %f, not %f", complexity, expected)
+	}
+}
+
+func benchmarkPool(b *testing.B, pooled bool) {
+	input := curie(b, 1024)
+	model := NewCDF16WithConfig(DefaultCDF16Config())
+	c := NewComplexityWithModel(model, model.Config)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if pooled {
+			c.Reset()
+		} else {
+			// the discarded tree isn't returned to the pool
+			model.Root, model.Clock = NewNode16(model.Config), 0
+		}
+		c.Train(input)
+	}
+}
+
+func BenchmarkPooled(b *testing.B) {
+	benchmarkPool(b, true)
+}
+
+func BenchmarkUnpooled(b *testing.B) {
+	benchmarkPool(b, false)
+}
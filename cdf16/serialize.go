@@ -0,0 +1,174 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// node16Gob is the gob form of a context node, the children are sorted by symbol so that the
+// encoding is deterministic
+type node16Gob struct {
+	Model    []uint16
+	Used     uint64
+	Keys     []uint16
+	Children []node16Gob
+}
+
+// cdf16Gob is the gob form of a CDF16
+type cdf16Gob struct {
+	Config CDF16Config
+	Order  ContextOrder
+	Clock  uint64
+	Mixin  [][]uint16
+	Root   node16Gob
+}
+
+func encodeNode16(n *Node16) node16Gob {
+	keys := make([]uint16, 0, n.Children.Len())
+	n.Children.Each(func(s uint16, _ *Node16) {
+		keys = append(keys, s)
+	})
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	children := make([]node16Gob, len(keys))
+	for i, key := range keys {
+		children[i] = encodeNode16(n.Children.Get(key))
+	}
+	return node16Gob{
+		Model:    n.Model,
+		Used:     n.Used,
+		Keys:     keys,
+		Children: children,
+	}
+}
+
+func decodeNode16(g node16Gob, cfg CDF16Config, depth int) (*Node16, error) {
+	if len(g.Model) != cfg.Size+1 {
+		return nil, fmt.Errorf("a node at depth %d has a model of %d entries, not %d", depth, len(g.Model), cfg.Size+1)
+	}
+	if g.Model[0] != 0 || int(g.Model[cfg.Size]) != cfg.Scale() {
+		return nil, fmt.Errorf("a node at depth %d has a model from %d to %d, not 0 to %d",
+			depth, g.Model[0], g.Model[cfg.Size], cfg.Scale())
+	}
+	for i := 1; i < len(g.Model); i++ {
+		if g.Model[i] < g.Model[i-1] {
+			return nil, fmt.Errorf("a node at depth %d has a model that decreases at %d", depth, i)
+		}
+	}
+	if len(g.Keys) != len(g.Children) {
+		return nil, fmt.Errorf("a node at depth %d has %d keys but %d children", depth, len(g.Keys), len(g.Children))
+	}
+	if len(g.Keys) > 0 && depth >= cfg.Depth {
+		return nil, fmt.Errorf("a node at depth %d has children but the depth is %d", depth, cfg.Depth)
+	}
+
+	var children Children16 = make(MapChildren16, len(g.Keys))
+	if cfg.SliceChildren {
+		children = &SliceChildren16{}
+	}
+	for i, key := range g.Keys {
+		if int(key) >= cfg.Size || (i > 0 && key <= g.Keys[i-1]) {
+			return nil, fmt.Errorf("a node at depth %d has the key %d out of order or outside of the alphabet", depth, key)
+		}
+		child, err := decodeNode16(g.Children[i], cfg, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		children.Set(key, child)
+	}
+	return &Node16{
+		Model:    g.Model,
+		Children: children,
+		Used:     g.Used,
+	}, nil
+}
+
+// validate checks that the config and the mixin table can be used to build a model
+func (g *cdf16Gob) validate() error {
+	cfg := g.Config
+	if cfg.Fixed < 1 || cfg.Fixed > 15 {
+		return fmt.Errorf("the shift %d is out of range [1, 15]", cfg.Fixed)
+	}
+	if cfg.Size < 1 || cfg.Size > MaxAlphabet || cfg.Size > cfg.Scale() {
+		return fmt.Errorf("the alphabet size %d is out of range [1, %d]", cfg.Size, MaxAlphabet)
+	}
+	if cfg.Rate < 0 || cfg.Rate > cfg.Fixed {
+		return fmt.Errorf("the rate %d is out of range [0, %d]", cfg.Rate, cfg.Fixed)
+	}
+	if cfg.Depth < 0 {
+		return fmt.Errorf("the depth %d is negative", cfg.Depth)
+	}
+	if len(g.Mixin) != cfg.Size {
+		return fmt.Errorf("the mixin table has %d entries, not %d", len(g.Mixin), cfg.Size)
+	}
+	for i, m := range g.Mixin {
+		if len(m) != cfg.Size+1 {
+			return fmt.Errorf("mixin %d has %d entries, not %d", i, len(m), cfg.Size+1)
+		}
+		if m[0] != 0 || int(m[cfg.Size]) != cfg.Scale() {
+			return fmt.Errorf("mixin %d is from %d to %d, not 0 to %d", i, m[0], m[cfg.Size], cfg.Scale())
+		}
+		for j := 1; j < len(m); j++ {
+			if m[j] < m[j-1] {
+				return fmt.Errorf("mixin %d decreases at %d", i, j)
+			}
+		}
+	}
+	return nil
+}
+
+// counter counts the bytes written to a writer
+type counter struct {
+	io.Writer
+	n int64
+}
+
+func (c *counter) Write(p []byte) (int, error) {
+	n, err := c.Writer.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// WriteTo writes the model with gob, the same model always produces the same bytes
+func (c *CDF16) WriteTo(w io.Writer) (int64, error) {
+	out := counter{Writer: w}
+	err := gob.NewEncoder(&out).Encode(cdf16Gob{
+		Config: c.Config,
+		Order:  c.Order,
+		Clock:  c.Clock,
+		Mixin:  c.Mixin,
+		Root:   encodeNode16(c.Root),
+	})
+	return out.n, err
+}
+
+// ReadCDF16 reads a model written by WriteTo, an error is returned if the model is inconsistent
+func ReadCDF16(r io.Reader) (*CDF16, error) {
+	var g cdf16Gob
+	err := gob.NewDecoder(r).Decode(&g)
+	if err != nil {
+		return nil, err
+	}
+	err = g.validate()
+	if err != nil {
+		return nil, err
+	}
+	root, err := decodeNode16(g.Root, g.Config, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &CDF16{
+		Config: g.Config,
+		Root:   root,
+		Mixin:  g.Mixin,
+		Order:  g.Order,
+		Clock:  g.Clock,
+	}, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cdf16
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"testing"
+)
+
+// curie returns the first length bytes of the test corpus
+func curie(t testing.TB, length int) []byte {
+	data, err := ioutil.ReadFile("../curie.wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data[:length]
+}
+
+func TestSerializeRoundTrip(t *testing.T) {
+	input := curie(t, 4096)
+	model := NewCDF16WithConfig(DefaultCDF16Config())
+	c := NewComplexityWithModel(model, model.Config)
+	c.Train(input)
+
+	var buffer bytes.Buffer
+	if _, err := model.WriteTo(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	written := append([]byte{}, buffer.Bytes()...)
+	loaded, err := ReadCDF16(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var again bytes.Buffer
+	if _, err := loaded.WriteTo(&again); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written, again.Bytes()) {
+		t.Fatal("the reloaded model doesn't serialize to the same bytes")
+	}
+	expected := c.Score(input)
+	if score := NewComplexityWithModel(loaded, loaded.Config).Score(input); score != expected {
+		t.Fatalf("the reloaded model scores %f, not %f", score, expected)
+	}
+}
+
+func TestSerializeInvalid(t *testing.T) {
+	model := NewCDF16WithConfig(DefaultCDF16Config())
+	NewComplexityWithModel(model, model.Config).Train([]byte("abracadabra"))
+	valid := func() cdf16Gob {
+		return cdf16Gob{
+			Config: model.Config,
+			Mixin:  model.Mixin,
+			Root:   encodeNode16(model.Root),
+		}
+	}
+
+	cases := map[string]func(g *cdf16Gob){
+		"more keys than children": func(g *cdf16Gob) {
+			g.Root.Keys = append(g.Root.Keys, 'z')
+		},
+		"short model": func(g *cdf16Gob) {
+			g.Root.Children[0].Model = g.Root.Children[0].Model[:10]
+		},
+		"decreasing model": func(g *cdf16Gob) {
+			g.Root.Model = append([]uint16{}, g.Root.Model...)
+			g.Root.Model[2] = 0
+		},
+		"key outside of the alphabet": func(g *cdf16Gob) {
+			g.Root.Keys = append([]uint16{}, g.Root.Keys...)
+			g.Root.Keys[len(g.Root.Keys)-1] = uint16(g.Config.Size)
+		},
+		"too deep": func(g *cdf16Gob) {
+			g.Config.Depth = 0
+		},
+		"short mixin": func(g *cdf16Gob) {
+			g.Mixin = g.Mixin[:1]
+		},
+		"decreasing mixin": func(g *cdf16Gob) {
+			g.Mixin = append([][]uint16{}, g.Mixin...)
+			g.Mixin[3] = append([]uint16{}, g.Mixin[3]...)
+			g.Mixin[3][5] = 0
+		},
+		"mixin short of the scale": func(g *cdf16Gob) {
+			g.Mixin = append([][]uint16{}, g.Mixin...)
+			g.Mixin[0] = append([]uint16{}, g.Mixin[0]...)
+			g.Mixin[0][g.Config.Size]--
+		},
+		"bad shift": func(g *cdf16Gob) {
+			g.Config.Fixed = 16
+		},
+	}
+	for name, corrupt := range cases {
+		g := valid()
+		corrupt(&g)
+		var buffer bytes.Buffer
+		if err := gob.NewEncoder(&buffer).Encode(g); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadCDF16(&buffer); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
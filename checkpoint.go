@@ -0,0 +1,98 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/gob"
+	"math/rand"
+	"os"
+)
+
+// CountingSource is a random source that counts its draws so that its state can be saved
+type CountingSource struct {
+	Initial int64
+	Draws   uint64
+	source  rand.Source64
+}
+
+// NewCountingSource creates a new counting source
+func NewCountingSource(seed int64) *CountingSource {
+	return &CountingSource{
+		Initial: seed,
+		source:  rand.NewSource(seed).(rand.Source64),
+	}
+}
+
+// RestoreCountingSource recreates a counting source after the given number of draws
+func RestoreCountingSource(seed int64, draws uint64) *CountingSource {
+	source := NewCountingSource(seed)
+	for source.Draws < draws {
+		source.Uint64()
+	}
+	return source
+}
+
+// Int63 returns a non-negative random 63 bit integer
+func (c *CountingSource) Int63() int64 {
+	c.Draws++
+	return c.source.Int63()
+}
+
+// Uint64 returns a random 64 bit integer
+func (c *CountingSource) Uint64() uint64 {
+	c.Draws++
+	return c.source.Uint64()
+}
+
+// Seed seeds the source and resets the draw count
+func (c *CountingSource) Seed(seed int64) {
+	c.Initial, c.Draws = seed, 0
+	c.source.Seed(seed)
+}
+
+// Checkpoint is the state of a run between generations
+type Checkpoint struct {
+	Generation    int
+	Seed          int64
+	Draws         uint64
+	Probabilities [NumOperators]float64
+	Genomes       []Genome
+	// Best and Stalled are the best fitness so far and the number of generations it hasn't improved
+	Best    float64
+	Stalled int
+	History []int
+	Deltas  OperatorDeltas
+}
+
+// Save writes the checkpoint to a file with gob, the file is replaced atomically
+func (c *Checkpoint) Save(path string) error {
+	temp := path + ".tmp"
+	out, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(out).Encode(c)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+// LoadCheckpoint reads a checkpoint written by Save
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	defer in.Close()
+	var c Checkpoint
+	err = gob.NewDecoder(in).Decode(&c)
+	return c, err
+}
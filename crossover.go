@@ -0,0 +1,124 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// PointCrossover swaps a single random token between copies of the parents
+func PointCrossover(r *rand.Rand, a, b *Genome) (Genome, Genome) {
+	cpa, cpb := a.Copy(), b.Copy()
+	x, y := r.Intn(len(cpa.Tokens)), r.Intn(len(cpb.Tokens))
+	cpa.Tokens[x], cpb.Tokens[y] = cpb.Tokens[y], cpa.Tokens[x]
+	return cpa, cpb
+}
+
+// TwoPointCrossover exchanges the tokens in a random interval [start, end) between copies of the parents.
+// The parents must be the same length
+func TwoPointCrossover(r *rand.Rand, a, b *Genome) (Genome, Genome) {
+	cpa, cpb := a.Copy(), b.Copy()
+	start, end := r.Intn(len(cpa.Tokens)), r.Intn(len(cpa.Tokens))
+	if start > end {
+		start, end = end, start
+	}
+	end++
+	for i := start; i < end; i++ {
+		cpa.Tokens[i], cpb.Tokens[i] = cpb.Tokens[i], cpa.Tokens[i]
+	}
+	return cpa, cpb
+}
+
+// UniformCrossover exchanges each token between copies of the parents with probability p.
+// The parents must be the same length
+func UniformCrossover(r *rand.Rand, a, b *Genome, p float64) (Genome, Genome) {
+	cpa, cpb := a.Copy(), b.Copy()
+	for i := range cpa.Tokens {
+		if r.Float64() < p {
+			cpa.Tokens[i], cpb.Tokens[i] = cpb.Tokens[i], cpa.Tokens[i]
+		}
+	}
+	return cpa, cpb
+}
+
+// Merge combines genomes that may number their tokens differently, like the best genomes of independent
+// runs. The token ids of other are first renumbered to the ids of the genome that cover the most of
+// the same positions, greedily from the largest overlap, and the rest get ids the genome doesn't use.
+// The child is the genome up to the run boundary nearest the middle and the renumbered other after it,
+// with its tokens numbered canonically like NewGenome, so that every id is in range, see Validate.
+// Genomes of different lengths can't be merged and the child is a copy of the genome
+func (g *Genome) Merge(other Genome) Genome {
+	child := g.Copy()
+	length := len(g.Tokens)
+	if len(other.Tokens) != length || length == 0 {
+		return child
+	}
+
+	type pair struct {
+		from, to int64
+	}
+	overlaps := make(map[pair]int)
+	for i, token := range other.Tokens {
+		overlaps[pair{token, g.Tokens[i]}]++
+	}
+	pairs := make([]pair, 0, len(overlaps))
+	for p := range overlaps {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i], pairs[j]
+		if overlaps[a] != overlaps[b] {
+			return overlaps[a] > overlaps[b]
+		}
+		if a.from != b.from {
+			return a.from < b.from
+		}
+		return a.to < b.to
+	})
+	renumber, used := make(map[int64]int64), make(map[int64]bool)
+	for _, p := range pairs {
+		if _, ok := renumber[p.from]; ok || used[p.to] {
+			continue
+		}
+		renumber[p.from], used[p.to] = p.to, true
+	}
+	for _, token := range g.Tokens {
+		used[token] = true
+	}
+	fresh := int64(0)
+	for _, p := range pairs {
+		if _, ok := renumber[p.from]; ok {
+			continue
+		}
+		for used[fresh] {
+			fresh++
+		}
+		renumber[p.from], used[fresh] = fresh, true
+	}
+
+	cut := length / 2
+	for cut > 0 && g.Tokens[cut-1] == g.Tokens[cut] {
+		cut--
+	}
+	for i := cut; i < length; i++ {
+		child.Tokens[i] = renumber[other.Tokens[i]]
+	}
+	child.canonical()
+	return child
+}
+
+// canonical renumbers the tokens with dense incrementing ids starting at 0 in order of first appearance
+func (g *Genome) canonical() {
+	ids := make(map[int64]int64)
+	for i, token := range g.Tokens {
+		id, ok := ids[token]
+		if !ok {
+			id = int64(len(ids))
+			ids[token] = id
+		}
+		g.Tokens[i] = id
+	}
+}
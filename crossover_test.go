@@ -0,0 +1,124 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// parents returns two genomes of the given length that differ at every position
+func parents(length int) (Genome, Genome) {
+	a, b := Genome{Tokens: make([]int64, length)}, Genome{Tokens: make([]int64, length)}
+	for i := range a.Tokens {
+		a.Tokens[i], b.Tokens[i] = int64(i), int64(length+i)
+	}
+	return a, b
+}
+
+func TestTwoPointCrossover(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	a, b := parents(50)
+	for i := 0; i < 1000; i++ {
+		cpa, cpb := TwoPointCrossover(rnd, &a, &b)
+		if len(cpa.Tokens) != len(a.Tokens) || len(cpb.Tokens) != len(b.Tokens) {
+			t.Fatalf("the children have %d and %d tokens", len(cpa.Tokens), len(cpb.Tokens))
+		}
+		// the children differ from the parents only in one interval, where they are exchanged
+		start, end := -1, -1
+		for j := range cpa.Tokens {
+			exchanged := cpa.Tokens[j] == b.Tokens[j] && cpb.Tokens[j] == a.Tokens[j]
+			if !exchanged && (cpa.Tokens[j] != a.Tokens[j] || cpb.Tokens[j] != b.Tokens[j]) {
+				t.Fatalf("the children have the tokens %d and %d at %d", cpa.Tokens[j], cpb.Tokens[j], j)
+			}
+			if exchanged {
+				if end != -1 && end != j {
+					t.Fatalf("the tokens at %d and %d are exchanged but not between", end-1, j)
+				}
+				if start == -1 {
+					start = j
+				}
+				end = j + 1
+			}
+		}
+		if start == -1 {
+			t.Fatal("no tokens are exchanged")
+		}
+	}
+	if a.Tokens[0] != 0 || b.Tokens[0] != 50 {
+		t.Fatal("the crossover changes the parents")
+	}
+}
+
+func TestUniformCrossover(t *testing.T) {
+	const p, trials = .3, 1000
+	rnd := rand.New(rand.NewSource(1))
+	a, b := parents(50)
+	exchanged := 0
+	for i := 0; i < trials; i++ {
+		cpa, cpb := UniformCrossover(rnd, &a, &b, p)
+		if len(cpa.Tokens) != len(a.Tokens) || len(cpb.Tokens) != len(b.Tokens) {
+			t.Fatalf("the children have %d and %d tokens", len(cpa.Tokens), len(cpb.Tokens))
+		}
+		for j := range cpa.Tokens {
+			if cpa.Tokens[j] == b.Tokens[j] && cpb.Tokens[j] == a.Tokens[j] {
+				exchanged++
+			} else if cpa.Tokens[j] != a.Tokens[j] || cpb.Tokens[j] != b.Tokens[j] {
+				t.Fatalf("the children have the tokens %d and %d at %d", cpa.Tokens[j], cpb.Tokens[j], j)
+			}
+		}
+	}
+	if rate := float64(exchanged) / float64(trials*len(a.Tokens)); math.Abs(rate-p) > .01 {
+		t.Fatalf("the tokens are exchanged at a rate of %f, not %f", rate, p)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	// the genomes number their tokens differently, and other splits the last token
+	g := Genome{Tokens: []int64{0, 0, 0, 1, 1, 1, 2, 2, 2, 3, 3, 3}}
+	other := Genome{Tokens: []int64{10, 10, 10, 11, 11, 11, 12, 12, 12, 13, 13, 14}}
+	child := g.Merge(other)
+	// the ids of other are renumbered to the ids of g that cover the same bytes, and the split off
+	// token gets an id that g doesn't use
+	expected := []int64{0, 0, 0, 1, 1, 1, 2, 2, 2, 3, 3, 4}
+	if !reflect.DeepEqual(child.Tokens, expected) {
+		t.Fatalf("the child is %v, not %v", child.Tokens, expected)
+	}
+	if !reflect.DeepEqual(child.Boundaries(), []int{3, 6, 9, 11}) {
+		t.Fatalf("the child has the boundaries %v", child.Boundaries())
+	}
+	if g.Tokens[11] != 3 || other.Tokens[11] != 14 {
+		t.Fatal("the merge changes the parents")
+	}
+
+	// the child is numbered canonically whatever the ids of the parents
+	g = Genome{Tokens: []int64{5, 5, 5, 4, 4, 4}}
+	other = Genome{Tokens: []int64{0, 0, 1, 1, 2, 2}}
+	if child := g.Merge(other); !reflect.DeepEqual(child.Tokens, []int64{0, 0, 0, 1, 2, 2}) {
+		t.Fatalf("the child of sparse ids is %v", child.Tokens)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		length := 1 + rnd.Intn(32)
+		a, b := Genome{Tokens: make([]int64, length)}, Genome{Tokens: make([]int64, length)}
+		for j := range a.Tokens {
+			a.Tokens[j], b.Tokens[j] = int64(rnd.Intn(length)), int64(rnd.Intn(length))
+		}
+		if child := a.Merge(b); child.Validate(length) != nil {
+			t.Fatalf("the child of %v and %v is invalid: %v", a.Tokens, b.Tokens, child.Validate(length))
+		}
+	}
+	a, b := parents(50)
+	if child := a.Merge(b); child.Validate(50) != nil {
+		t.Fatalf("the child of dense parents is invalid: %v", child.Validate(50))
+	}
+
+	// genomes of different lengths aren't merged
+	if short := g.Merge(Genome{Tokens: []int64{5}}); !reflect.DeepEqual(short.Tokens, g.Tokens) {
+		t.Fatalf("a merge with a shorter genome is %v", short.Tokens)
+	}
+}
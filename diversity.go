@@ -0,0 +1,117 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math/rand"
+)
+
+// Distance is the Hamming distance between the genomes, the number of positions where their token
+// ids differ. If the lengths differ the positions past the end of the shorter genome all differ
+func (g *Genome) Distance(other Genome) int {
+	a, b := g.Tokens, other.Tokens
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	distance := len(b) - len(a)
+	for i, token := range a {
+		if token != b[i] {
+			distance++
+		}
+	}
+	return distance
+}
+
+// ShareFitness penalizes the fitness of each genome by how crowded its niche is. Every other genome
+// within radius tokens adds strength times 1 - distance/radius to a factor the fitness is multiplied by,
+// so lower fitness is still better. This is O(n^2) in the size of the population
+func ShareFitness(genomes []Genome, radius int, strength float64) {
+	if radius <= 0 || strength <= 0 {
+		return
+	}
+	niches := make([]float64, len(genomes))
+	for i := range genomes {
+		for j := i + 1; j < len(genomes); j++ {
+			distance := genomes[i].Distance(genomes[j])
+			if distance < radius {
+				share := 1 - float64(distance)/float64(radius)
+				niches[i] += share
+				niches[j] += share
+			}
+		}
+	}
+	for i := range genomes {
+		genomes[i].Fitness *= 1 + strength*niches[i]
+	}
+}
+
+// DiversitySamples is the number of pairs of genomes Diversity samples when the population has
+// more pairs than that
+const DiversitySamples = 1024
+
+// Diversity is the mean pairwise token distance of the genomes, zero when they are all clones.
+// Large populations are estimated from DiversitySamples random pairs drawn with r
+func Diversity(r *rand.Rand, genomes []Genome) float64 {
+	n := len(genomes)
+	if n < 2 {
+		return 0
+	}
+	pairs, total := n*(n-1)/2, 0
+	if pairs <= DiversitySamples {
+		for i := range genomes {
+			for j := i + 1; j < n; j++ {
+				total += genomes[i].Distance(genomes[j])
+			}
+		}
+		return float64(total) / float64(pairs)
+	}
+	for k := 0; k < DiversitySamples; k++ {
+		i, j := r.Intn(n), r.Intn(n-1)
+		if j >= i {
+			j++
+		}
+		total += genomes[i].Distance(genomes[j])
+	}
+	return float64(total) / DiversitySamples
+}
+
+// Hash is a 64 bit FNV-1a hash of the token ids
+func (g *Genome) Hash() uint64 {
+	hash, buffer := fnv.New64a(), make([]byte, 8)
+	for _, token := range g.Tokens {
+		binary.LittleEndian.PutUint64(buffer, uint64(token))
+		hash.Write(buffer)
+	}
+	return hash.Sum64()
+}
+
+// Dedup replaces each genome that has the same tokens as an earlier genome with a genome from fresh
+// and returns the number replaced. Fresh genomes are drawn until one isn't a duplicate, so fresh must
+// be able to produce distinct genomes
+func Dedup(genomes []Genome, fresh func() Genome) int {
+	seen, replaced := make(map[uint64][]int, len(genomes)), 0
+	duplicate := func(g *Genome, hash uint64) bool {
+		for _, j := range seen[hash] {
+			if genomes[j].Distance(*g) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	for i := range genomes {
+		hash := genomes[i].Hash()
+		if duplicate(&genomes[i], hash) {
+			replaced++
+			for duplicate(&genomes[i], hash) {
+				genomes[i] = fresh()
+				hash = genomes[i].Hash()
+			}
+		}
+		seen[hash] = append(seen[hash], i)
+	}
+	return replaced
+}
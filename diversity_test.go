@@ -0,0 +1,130 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func TestShareFitness(t *testing.T) {
+	clone := Genome{Tokens: []int64{0, 0, 1, 1}, Fitness: 2}
+	near := Genome{Tokens: []int64{0, 0, 1, 2}, Fitness: 2}
+	far := Genome{Tokens: []int64{3, 3, 3, 3}, Fitness: 2}
+	genomes := []Genome{clone, clone.Copy(), near, far}
+	genomes[1].Fitness = 2
+	ShareFitness(genomes, 2, 1)
+	// the clones share with each other fully and with the near genome by half
+	expected := []float64{2 * 2.5, 2 * 2.5, 2 * 2, 2}
+	for i, g := range genomes {
+		if g.Fitness != expected[i] {
+			t.Fatalf("genome %d has a shared fitness of %f, not %f", i, g.Fitness, expected[i])
+		}
+	}
+
+	diversity := func(radius int) float64 {
+		cfg := testConfig()
+		cfg.MaxGenerations, cfg.ShareRadius, cfg.ShareStrength = 30, radius, 1
+		ga := NewGA(testCorpus, cfg)
+		ga.Run(context.Background())
+		return Diversity(rand.New(rand.NewSource(1)), ga.Genomes)
+	}
+	if shared, unshared := diversity(40), diversity(0); shared <= unshared {
+		t.Fatalf("the diversity with sharing is %f and without is %f", shared, unshared)
+	}
+}
+
+func TestDiversity(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	// 10 genomes are scored on every pair and 100 on a sample of pairs
+	for _, n := range []int{10, 100} {
+		clones, varied := make([]Genome, n), make([]Genome, n)
+		original := NewGenome(rnd, 64, false)
+		for i := range clones {
+			clones[i], varied[i] = original.Copy(), NewGenome(rnd, 64, false)
+		}
+		if diversity := Diversity(rnd, clones); diversity != 0 {
+			t.Fatalf("the diversity of %d clones is %f", n, diversity)
+		}
+		if diversity := Diversity(rnd, varied); diversity <= 0 {
+			t.Fatalf("the diversity of %d random genomes is %f", n, diversity)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	g := Genome{Tokens: []int64{0, 0, 1, 1}}
+	cases := []struct {
+		other    []int64
+		distance int
+	}{
+		{[]int64{0, 0, 1, 1}, 0},
+		{[]int64{2, 3, 2, 0}, 4},
+		{[]int64{0, 1, 1, 2}, 2},
+		// the positions past the end of the shorter genome all differ
+		{[]int64{0, 0}, 2},
+		{[]int64{0, 0, 1, 1, 1, 1}, 2},
+		{nil, 4},
+	}
+	for _, c := range cases {
+		other := Genome{Tokens: c.other}
+		if distance := g.Distance(other); distance != c.distance {
+			t.Fatalf("the distance to %v is %d, not %d", c.other, distance, c.distance)
+		}
+		if distance := other.Distance(g); distance != c.distance {
+			t.Fatalf("the distance from %v is %d, not %d", c.other, distance, c.distance)
+		}
+	}
+}
+
+func TestDedup(t *testing.T) {
+	unique := func(genomes []Genome) {
+		for i := range genomes {
+			for j := i + 1; j < len(genomes); j++ {
+				if genomes[i].Distance(genomes[j]) == 0 {
+					t.Fatalf("the genomes %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+	rnd := rand.New(rand.NewSource(1))
+	fresh := func() Genome {
+		return NewGenome(rnd, 64, false)
+	}
+	original, other := fresh(), fresh()
+	genomes := []Genome{original, original.Copy(), other, original.Copy(), other.Copy()}
+	if replaced := Dedup(genomes, fresh); replaced != 3 {
+		t.Fatalf("%d genomes are replaced, not 3", replaced)
+	}
+	unique(genomes)
+	if genomes[0].Distance(original) != 0 || genomes[2].Distance(other) != 0 {
+		t.Fatal("the first of the equal genomes is replaced")
+	}
+
+	// a fresh genome that duplicates an earlier genome is drawn again
+	draws := 0
+	repeating := func() Genome {
+		draws++
+		if draws < 3 {
+			return original.Copy()
+		}
+		return fresh()
+	}
+	genomes = []Genome{original, original.Copy()}
+	if replaced := Dedup(genomes, repeating); replaced != 1 || draws != 3 {
+		t.Fatalf("%d genomes are replaced with %d draws", replaced, draws)
+	}
+	unique(genomes)
+
+	cfg := testConfig()
+	cfg.Dedup = true
+	ga := NewGA(testCorpus, cfg)
+	for i := 0; i < 5; i++ {
+		ga.Evaluate(context.Background())
+		ga.Breed()
+		unique(ga.Genomes)
+	}
+}
@@ -0,0 +1,555 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/pointlander/token/cdf16"
+)
+
+// GAConfig are the parameters of the genetic algorithm
+type GAConfig struct {
+	// Window is the number of leading bytes of the corpus to tokenize, 0 for all of it
+	Window int
+	// Population is the size of the population
+	Population int
+	// Elite is the number of best genomes copied unchanged into the next generation, the rest of the
+	// population is filled with children. 0, or Population and above, keeps the whole population and
+	// adds Population children to it. Parents are always selected from the best Population genomes
+	Elite int
+	// Selection is the parent selection scheme: top10 draws parents uniformly from the best
+	// SelectionPool genomes, tournament runs a tournament over all of them
+	Selection string
+	// SelectionPool is the number of best genomes top10 selection draws parents from, 0 for all of them
+	SelectionPool int
+	// TournamentSize is the number of genomes in a selection tournament
+	TournamentSize int
+	// Crossover is the crossover operator: point1, point2 or uniform
+	Crossover string
+	// UniformRate is the probability that uniform crossover exchanges a token
+	UniformRate float64
+	// MutationStart is the fraction of the tokens mutated in the first generation, see MutationCount
+	MutationStart float64
+	// MutationEnd is the number of tokens mutated once the schedule has decayed, see MutationCount
+	MutationEnd float64
+	// MutationDecay is the time constant in generations of the decay of the schedule, see MutationCount
+	MutationDecay float64
+	// CanonicalInit numbers the runs of the random genomes with dense incrementing ids starting at 0
+	CanonicalInit bool
+	// Operators are the active operators, each child is produced by one of them drawn uniformly or
+	// adaptively. None is every operator
+	Operators []Operator
+	// Mutation are the probabilities of the structural mutations
+	Mutation Mutation
+	// Fitness are the parameters of the fitness function
+	Fitness FitnessConfig
+	// MaxGenerations is the number of generations after which the run stops, 0 for no limit
+	MaxGenerations int
+	// Patience is the number of generations without an improvement of Epsilon after which the run
+	// stops, 0 for no limit
+	Patience int
+	// Epsilon is the smallest decrease of the best fitness that counts as an improvement
+	Epsilon float64
+	// Deltas tracks the fitness improvement of each operator
+	Deltas bool
+	// Adaptive adapts the operator selection probabilities to the fitness improvements of the operators
+	Adaptive bool
+	// AdaptiveRate is the learning rate of the adaptive operator selection
+	AdaptiveRate float64
+	// ShareRadius is the distance within which genomes share fitness, 0 disables sharing, see ShareFitness
+	ShareRadius int
+	// ShareStrength weights the fitness penalty of each genome within ShareRadius, see ShareFitness
+	ShareStrength float64
+	// Strict crashes on a panic in fitness evaluation
+	Strict bool
+	// Dedup replaces duplicate genomes with random ones after breeding
+	Dedup bool
+	// Workers is the number of goroutines that compute fitness, 0 for one per CPU
+	Workers int
+	// MemLimit is the heap size in bytes above which the fitness cache is emptied and the pooled
+	// context nodes are dropped after a generation, 0 for no limit
+	MemLimit uint64
+	// Seed is the random seed, the same seed and config reproduce a run exactly
+	Seed int64
+	// Genomes seed the initial population, the rest of it is random
+	Genomes []Genome
+	// ValidationWindow is a slice of the corpus held out from evolution, it should be outside of the
+	// first Window bytes. A zero length disables validation
+	ValidationWindow Span
+	// ValidationEvery is the number of generations between validations, or 1 if it isn't positive, see
+	// GA.ValidationFitness
+	ValidationEvery int
+}
+
+// Span is a slice of a corpus
+type Span struct {
+	Offset, Length int
+}
+
+// DefaultGAConfig returns the default parameters of the genetic algorithm, they reproduce the original
+// hard coded run: a population of 100 tokenizing the first 1024 bytes, top 10 selection, depth 2
+// context models and seed 1
+func DefaultGAConfig() GAConfig {
+	return GAConfig{
+		Window:          1024,
+		Population:      100,
+		Selection:       "top10",
+		SelectionPool:   10,
+		TournamentSize:  3,
+		Crossover:       "point1",
+		UniformRate:     .5,
+		MutationStart:   .01,
+		MutationEnd:     1,
+		MutationDecay:   100,
+		Epsilon:         1e-6,
+		AdaptiveRate:    .1,
+		ShareStrength:   .01,
+		Fitness:         DefaultFitnessConfig(),
+		Seed:            1,
+		ValidationEvery: 10,
+	}
+}
+
+// GenerationStats are the statistics of an evaluated generation
+type GenerationStats struct {
+	Generation int
+	Elapsed    time.Duration
+	Best       float64
+	Mean       float64
+	Distinct   int
+	Diversity  float64
+	// Validation is the fitness of the validation window under the best genome if Validated is set
+	Validation float64
+	Validated  bool
+}
+
+// GA is the state of a run of the genetic algorithm
+type GA struct {
+	Config GAConfig
+	// Corpus is the corpus the genomes tokenize
+	Corpus     []byte
+	Genomes    []Genome
+	Generation int
+	Source     *CountingSource
+	Rand       *rand.Rand
+	Adaptive   *AdaptiveOperators
+	Deltas     OperatorDeltas
+	// History is the distinct token count of the best genome of every generation
+	History []int
+
+	elite     int
+	children  int
+	best      float64
+	stalled   int
+	offspring []Genome
+	// last are the survivors of the last evaluated generation
+	last []Genome
+	// validation is the validation window of the corpus
+	validation []byte
+	// cache is the fitness of the genomes already scored, by hash
+	cache map[uint64][]Genome
+}
+
+// NewGA creates the initial population for the corpus. The GA keeps all of its state, so several can
+// run at the same time
+func NewGA(corpus []byte, cfg GAConfig) *GA {
+	var validation []byte
+	if span := cfg.ValidationWindow; span.Length > 0 && span.Offset >= 0 && span.Offset < len(corpus) {
+		end := span.Offset + span.Length
+		if end > len(corpus) {
+			end = len(corpus)
+		}
+		validation = corpus[span.Offset:end]
+	}
+	if cfg.Window > 0 && len(corpus) > cfg.Window {
+		corpus = corpus[:cfg.Window]
+	}
+	if cfg.Population < 1 {
+		cfg.Population = 1
+	}
+	if len(cfg.Operators) == 0 {
+		cfg.Operators = AllOperators
+	}
+	elite, children := cfg.Elite, cfg.Population-cfg.Elite
+	if elite <= 0 || elite >= cfg.Population {
+		elite, children = cfg.Population, cfg.Population
+	}
+	source := NewCountingSource(cfg.Seed)
+	ga := GA{
+		Config:     cfg,
+		Corpus:     corpus,
+		Genomes:    make([]Genome, 0, cfg.Population),
+		Source:     source,
+		Rand:       rand.New(source),
+		Adaptive:   NewAdaptiveOperators(cfg.AdaptiveRate, cfg.Operators...),
+		History:    make([]int, 0, 1024),
+		elite:      elite,
+		children:   children,
+		best:       math.MaxFloat64,
+		offspring:  make([]Genome, 0, cfg.Population),
+		validation: validation,
+	}
+	for _, genome := range cfg.Genomes {
+		if len(ga.Genomes) < cfg.Population {
+			ga.Genomes = append(ga.Genomes, genome.Copy())
+		}
+	}
+	for len(ga.Genomes) < cfg.Population {
+		ga.Genomes = append(ga.Genomes, ga.newGenome())
+	}
+	return &ga
+}
+
+// newGenome creates a new random genome for the corpus
+func (ga *GA) newGenome() Genome {
+	return NewGenome(ga.Rand, len(ga.Corpus), ga.Config.CanonicalInit)
+}
+
+// Restore continues the run from a checkpoint
+func (ga *GA) Restore(checkpoint Checkpoint) {
+	ga.Source = RestoreCountingSource(checkpoint.Seed, checkpoint.Draws)
+	ga.Rand = rand.New(ga.Source)
+	ga.Genomes, ga.Generation = checkpoint.Genomes, checkpoint.Generation
+	ga.Adaptive.Probabilities = checkpoint.Probabilities
+	ga.best, ga.stalled = checkpoint.Best, checkpoint.Stalled
+	ga.History, ga.Deltas = append(ga.History[:0], checkpoint.History...), checkpoint.Deltas
+}
+
+// Checkpoint is the state of the run for continuing it later
+func (ga *GA) Checkpoint() Checkpoint {
+	return Checkpoint{
+		Generation:    ga.Generation,
+		Seed:          ga.Source.Initial,
+		Draws:         ga.Source.Draws,
+		Probabilities: ga.Adaptive.Probabilities,
+		Genomes:       ga.Genomes,
+		Best:          ga.best,
+		Stalled:       ga.stalled,
+		History:       ga.History,
+		Deltas:        ga.Deltas,
+	}
+}
+
+// Evaluate computes the fitness of the population, sorts it from best to worst, and keeps the best
+// Population genomes as the survivors that parents are selected from. If the context is done before
+// every genome is scored the generation is abandoned and the population goes back to the survivors of
+// the last evaluated generation, or to the first genome scored in full if there is none, and complete
+// is false
+func (ga *GA) Evaluate(ctx context.Context) (stats GenerationStats, complete bool) {
+	genomes := ga.Genomes
+	if ga.cache == nil {
+		ga.cache = make(map[uint64][]Genome)
+	}
+	hashes, scored := make([]uint64, len(genomes)), make([]bool, len(genomes))
+	fitness := func(i int, model *cdf16.Complexity) {
+		if !ga.Config.Strict {
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Fprintf(os.Stderr, "fitness panic: %v tokens=%v\n", r, genomes[i].Tokens)
+					genomes[i].Fitness = math.MaxFloat64
+				}
+			}()
+		}
+		if !genomes[i].computeFitness(ctx, ga.Corpus, ga.Config.Fitness, model) {
+			scored[i] = true
+		}
+	}
+	workers := ga.Config.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	start := time.Now()
+	indexes, done := make(chan int, len(genomes)), make(chan bool, workers)
+	for i := range genomes {
+		hashes[i] = genomes[i].Hash()
+		if fitness, ok := ga.cached(&genomes[i], hashes[i]); ok {
+			genomes[i].Fitness = fitness
+			continue
+		}
+		indexes <- i
+	}
+	close(indexes)
+	for w := 0; w < workers; w++ {
+		go func() {
+			// each worker reuses a model for all of the genomes it scores
+			model := cdf16.NewComplexity(ga.Config.Fitness.Depth)
+			for i := range indexes {
+				fitness(i, model)
+			}
+			done <- true
+		}()
+	}
+	for w := 0; w < workers; w++ {
+		<-done
+	}
+	elapsed := time.Since(start)
+	if ctx.Err() != nil {
+		if ga.last == nil {
+			genomes[0].ComputeFitness(ga.Corpus, ga.Config.Fitness)
+			ga.last = []Genome{genomes[0]}
+		}
+		ga.Genomes = append(make([]Genome, 0, len(ga.last)+ga.Config.Population), ga.last...)
+		return GenerationStats{Generation: ga.Generation, Elapsed: elapsed}, false
+	}
+	for i := range genomes {
+		// a panic leaves the fitness at the maximum, it isn't cached so the genome is retried
+		if scored[i] && genomes[i].Fitness != math.MaxFloat64 {
+			if _, ok := ga.cached(&genomes[i], hashes[i]); !ok {
+				cp := genomes[i].Copy()
+				cp.Fitness = genomes[i].Fitness
+				ga.cache[hashes[i]] = append(ga.cache[hashes[i]], cp)
+			}
+		}
+	}
+	ga.trim()
+	ShareFitness(genomes, ga.Config.ShareRadius, ga.Config.ShareStrength)
+	// a stable sort keeps genomes of equal fitness in population order, so runs with the same seed
+	// are repeatable
+	sort.SliceStable(genomes, func(i, j int) bool {
+		return genomes[i].Fitness < genomes[j].Fitness
+	})
+	mean := 0.0
+	for _, genome := range genomes {
+		mean += genome.Fitness
+	}
+	mean /= float64(len(genomes))
+	// a separate source keeps the sampled estimate from perturbing the run
+	diversity := Diversity(rand.New(rand.NewSource(int64(ga.Generation))), genomes)
+	if ga.Config.Deltas || ga.Config.Adaptive {
+		var current OperatorDeltas
+		current.Add(genomes)
+		ga.Deltas.Accumulate(&current)
+		if ga.Config.Adaptive {
+			ga.Adaptive.Update(&current)
+		}
+	}
+	count := ga.Config.Population
+	if count > len(genomes) {
+		count = len(genomes)
+	}
+	survivors := make([]Genome, 0, ga.elite+ga.children)
+	for _, genome := range genomes[:count] {
+		cp := genome.Copy()
+		cp.Fitness = genome.Fitness
+		survivors = append(survivors, cp)
+	}
+	ga.Genomes = survivors
+	ga.last = append(ga.last[:0], survivors...)
+	tokens := make(map[int64]bool)
+	for _, t := range survivors[0].Tokens {
+		tokens[t] = true
+	}
+	ga.History = append(ga.History, len(tokens))
+
+	if survivors[0].Fitness < ga.best-ga.Config.Epsilon {
+		ga.best, ga.stalled = survivors[0].Fitness, 0
+	} else {
+		ga.stalled++
+	}
+
+	stats = GenerationStats{
+		Generation: ga.Generation,
+		Elapsed:    elapsed,
+		Best:       survivors[0].Fitness,
+		Mean:       mean,
+		Distinct:   len(tokens),
+		Diversity:  diversity,
+	}
+	if every := ga.Config.ValidationEvery; ga.validation != nil && (every <= 0 || ga.Generation%every == 0) {
+		stats.Validation, stats.Validated = ga.ValidationFitness(survivors[0]), true
+	}
+	return stats, true
+}
+
+// cached looks up the fitness of a genome that was already scored, before fitness sharing
+func (ga *GA) cached(g *Genome, hash uint64) (float64, bool) {
+	for _, c := range ga.cache[hash] {
+		if c.Distance(*g) == 0 {
+			return c.Fitness, true
+		}
+	}
+	return 0, false
+}
+
+// trim empties the fitness cache and drops the pooled context nodes if the heap is over MemLimit
+func (ga *GA) trim() {
+	if ga.Config.MemLimit == 0 {
+		return
+	}
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	if stats.HeapAlloc <= ga.Config.MemLimit {
+		return
+	}
+	entries := 0
+	for _, genomes := range ga.cache {
+		entries += len(genomes)
+	}
+	ga.cache = make(map[uint64][]Genome)
+	nodes := cdf16.DrainNodes()
+	fmt.Fprintf(os.Stderr, "heap %d bytes is over the limit of %d: trimmed %d cached fitnesses and %d pooled nodes\n",
+		stats.HeapAlloc, ga.Config.MemLimit, entries, nodes)
+}
+
+// ValidationFitness is the fitness of the validation window tokenized with the vocabulary of the
+// genome, see Genome.Tokenize. It is 0 if there is no validation window
+func (ga *GA) ValidationFitness(g Genome) float64 {
+	if ga.validation == nil {
+		return 0
+	}
+	tokenized := g.Tokenize(ga.Corpus, ga.validation)
+	tokenized.ComputeFitness(ga.validation, ga.Config.Fitness)
+	return tokenized.Fitness
+}
+
+// Done is true when the evaluated generation has stalled for Patience generations or is the last one
+func (ga *GA) Done() bool {
+	converged := ga.Config.Patience > 0 && ga.stalled >= ga.Config.Patience
+	capped := ga.Config.MaxGenerations > 0 && ga.Generation+1 >= ga.Config.MaxGenerations
+	return converged || capped
+}
+
+// Breed replaces the survivors after the elite with the offspring of the evaluated generation and
+// advances to the next generation
+func (ga *GA) Breed() {
+	genomes, rnd, size, operators := ga.Genomes, ga.Rand, ga.children, ga.Config.Operators
+	pool := SelectionBound(len(genomes), ga.Config.SelectionPool)
+	mutations := MutationCount(ga.Generation, len(ga.Corpus), ga.Config.MutationStart, ga.Config.MutationEnd, ga.Config.MutationDecay)
+	selectParent := func() int {
+		if ga.Config.Selection == "tournament" {
+			return Tournament(rnd, genomes, ga.Config.TournamentSize)
+		}
+		return rnd.Intn(pool)
+	}
+	offspring := ga.offspring[:0]
+	for len(offspring) < size {
+		operator := operators[rnd.Intn(len(operators))]
+		if ga.Config.Adaptive {
+			operator = ga.Adaptive.Select(rnd)
+		}
+		switch operator {
+		case OperatorMutate:
+			a := selectParent()
+			cp := genomes[a].MutateN(rnd, int64(len(ga.Corpus)-1), mutations, ga.Config.Mutation)
+			cp.Operator, cp.Parent = OperatorMutate, genomes[a].Fitness
+			offspring = append(offspring, cp)
+		case OperatorSwap:
+			a, b := selectParent(), selectParent()
+			var cpa, cpb Genome
+			switch ga.Config.Crossover {
+			case "point2":
+				cpa, cpb = TwoPointCrossover(rnd, &genomes[a], &genomes[b])
+			case "uniform":
+				cpa, cpb = UniformCrossover(rnd, &genomes[a], &genomes[b], ga.Config.UniformRate)
+			default:
+				cpa, cpb = PointCrossover(rnd, &genomes[a], &genomes[b])
+			}
+			parent := math.Min(genomes[a].Fitness, genomes[b].Fitness)
+			cpa.Operator, cpa.Parent = OperatorSwap, parent
+			cpb.Operator, cpb.Parent = OperatorSwap, parent
+			offspring = append(offspring, cpa)
+			if len(offspring) < size {
+				offspring = append(offspring, cpb)
+			}
+		case OperatorCopy:
+			a, b := selectParent(), selectParent()
+			cpa, cpb := genomes[a].Copy(), genomes[b].Copy()
+			x, y := rnd.Intn(len(cpa.Tokens)), rnd.Intn(len(cpb.Tokens))
+			cpa.Tokens[x] = cpb.Tokens[y]
+			parent := math.Min(genomes[a].Fitness, genomes[b].Fitness)
+			cpa.Operator, cpa.Parent = OperatorCopy, parent
+			cpb.Operator, cpb.Parent = OperatorCopy, parent
+			offspring = append(offspring, cpa)
+			if len(offspring) < size {
+				offspring = append(offspring, cpb)
+			}
+		}
+	}
+	ga.offspring = offspring
+
+	elite := ga.elite
+	if elite > len(genomes) {
+		elite = len(genomes)
+	}
+	ga.Genomes = append(genomes[:elite], offspring...)
+	if ga.Config.Dedup {
+		Dedup(ga.Genomes, ga.newGenome)
+	}
+	ga.Generation++
+}
+
+// Run runs the genetic algorithm until it is done or the context is canceled and returns the best genome
+func (ga *GA) Run(ctx context.Context) Genome {
+	for {
+		_, complete := ga.Evaluate(ctx)
+		if !complete || ga.Done() {
+			return ga.Genomes[0]
+		}
+		ga.Breed()
+	}
+}
+
+// RunGA runs the genetic algorithm on the corpus and returns the best genome. The config should set
+// MaxGenerations or Patience, or the run doesn't stop
+func RunGA(corpus []byte, cfg GAConfig) Genome {
+	return NewGA(corpus, cfg).Run(context.Background())
+}
+
+// Tiles splits the corpus into consecutive non-overlapping windows of the given size, the last tile
+// is shorter if the size doesn't divide the length of the corpus. A size that isn't positive is one
+// tile of the whole corpus
+func Tiles(corpus []byte, window int) [][]byte {
+	if window <= 0 || window >= len(corpus) {
+		return [][]byte{corpus}
+	}
+	tiles := make([][]byte, 0, (len(corpus)+window-1)/window)
+	for start := 0; start < len(corpus); start += window {
+		end := start + window
+		if end > len(corpus) {
+			end = len(corpus)
+		}
+		tiles = append(tiles, corpus[start:end])
+	}
+	return tiles
+}
+
+// RunTiles runs the genetic algorithm on each tile of the corpus in order and returns the best genome
+// of each tile. The tiles are cfg.Window bytes long, and the genome of a tile has one token for each
+// of its bytes. cfg.Genomes seed the first tile, and the best genome of a tile seeds the next one
+// resized to its length. Only the last tile can be shorter, so the seed is the best genome unchanged
+// except on the last tile, where it is truncated
+func RunTiles(corpus []byte, cfg GAConfig) []Genome {
+	return RunTilesFunc(Tiles(corpus, cfg.Window), cfg, func(_ int, ga *GA) bool {
+		ga.Run(context.Background())
+		return true
+	})
+}
+
+// RunTilesFunc creates the GA of each tile in order, seeded like RunTiles does, and calls run to run
+// it. The best genome of each GA that was run is returned, and the tiles after a call to run that
+// returns false are skipped
+func RunTilesFunc(tiles [][]byte, cfg GAConfig, run func(tile int, ga *GA) bool) []Genome {
+	best := make([]Genome, 0, len(tiles))
+	for i, tile := range tiles {
+		if i > 0 {
+			cfg.Genomes = []Genome{best[i-1].Resize(len(tile))}
+		}
+		ga := NewGA(tile, cfg)
+		next := run(i, ga)
+		best = append(best, ga.Genomes[0])
+		if !next {
+			break
+		}
+	}
+	return best
+}
@@ -0,0 +1,653 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunGA(t *testing.T) {
+	cfg := testConfig()
+	a, b := RunGA(testCorpus, cfg), RunGA(testCorpus, cfg)
+	if err := a.Validate(len(testCorpus)); err != nil {
+		t.Fatal(err)
+	}
+	if a.Fitness != b.Fitness || a.Distance(b) != 0 {
+		t.Fatalf("runs with the same seed differ: %f and %f", a.Fitness, b.Fitness)
+	}
+	check := a.Copy()
+	check.ComputeFitness(testCorpus, cfg.Fitness)
+	if check.Fitness != a.Fitness {
+		t.Fatalf("the best genome has fitness %f but it computes to %f", a.Fitness, check.Fitness)
+	}
+}
+
+func TestShortRun(t *testing.T) {
+	SetCorpus(testCorpus)
+	cfg := testConfig()
+	ga := NewGA(testCorpus, cfg)
+	generations := 0
+	for {
+		stats, complete := ga.Evaluate(context.Background())
+		if !complete || stats.Generation != generations {
+			t.Fatalf("generation %d is complete: %t", stats.Generation, complete)
+		}
+		generations++
+		if ga.Done() {
+			break
+		}
+		ga.Breed()
+	}
+	if generations != cfg.MaxGenerations {
+		t.Fatalf("the run stopped after %d generations, not %d", generations, cfg.MaxGenerations)
+	}
+
+	data, err := json.Marshal(&ga.Genomes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output genomeJSON
+	if err := json.Unmarshal(data, &output); err != nil {
+		t.Fatal(err)
+	}
+	if joined := strings.Join(output.Segments, ""); joined != string(testCorpus) {
+		t.Fatalf("the segments of the best genome are %q", joined)
+	}
+}
+
+func TestSmallPopulation(t *testing.T) {
+	cfg := testConfig()
+	cfg.Population = 4
+	for _, selection := range []string{"top10", "tournament"} {
+		cfg.Selection = selection
+		ga := NewGA(testCorpus, cfg)
+		ga.Evaluate(context.Background())
+		ga.Breed()
+		if stats, complete := ga.Evaluate(context.Background()); !complete || stats.Generation != 1 {
+			t.Fatalf("the second generation with %s selection is %d", selection, stats.Generation)
+		}
+	}
+}
+
+func TestConcurrentGAs(t *testing.T) {
+	configs := []GAConfig{testConfig(), testConfig()}
+	configs[1].Seed = 2
+	expected := make([]Genome, len(configs))
+	for i, cfg := range configs {
+		expected[i] = RunGA(testCorpus, cfg)
+	}
+
+	concurrent, done := make([]Genome, len(configs)), make(chan bool, len(configs))
+	for i := range configs {
+		go func(i int) {
+			concurrent[i] = RunGA(testCorpus, configs[i])
+			done <- true
+		}(i)
+	}
+	for range configs {
+		<-done
+	}
+	for i := range configs {
+		if concurrent[i].Fitness != expected[i].Fitness || concurrent[i].Distance(expected[i]) != 0 {
+			t.Fatalf("the concurrent run with seed %d differs: %f and %f",
+				configs[i].Seed, concurrent[i].Fitness, expected[i].Fitness)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxGenerations = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(50*time.Millisecond, cancel)
+	// the run has no limit, so it only stops when it is canceled
+	best := NewGA(testCorpus, cfg).Run(ctx)
+	if err := best.Validate(len(testCorpus)); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "best")
+	if err := best.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGenome(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Distance(best) != 0 {
+		t.Fatal("the saved genome differs from the best genome")
+	}
+}
+
+func TestCancelImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	best := NewGA(testCorpus, testConfig()).Run(ctx)
+	if err := best.Validate(len(testCorpus)); err != nil {
+		t.Fatal(err)
+	}
+	expected := best.Copy()
+	expected.ComputeFitness(testCorpus, testConfig().Fitness)
+	if best.Fitness != expected.Fitness {
+		t.Fatalf("the genome has fitness %f, not %f", best.Fitness, expected.Fitness)
+	}
+}
+
+func TestDefaultGAConfig(t *testing.T) {
+	corpus := curie(t)
+	cfg := DefaultGAConfig()
+
+	// the original run seeded the global source with 1 and drew every genome from it
+	r, legacy := rand.New(rand.NewSource(1)), math.MaxFloat64
+	for i := 0; i < 100; i++ {
+		tokens := make([]int64, len(corpus))
+		token := int64(r.Intn(len(corpus)))
+		for j := range tokens {
+			tokens[j] = token
+			if r.Intn(8) == 0 {
+				token = int64(r.Intn(len(corpus)))
+			}
+		}
+		g := Genome{Tokens: tokens}
+		g.ComputeFitness(corpus, cfg.Fitness)
+		legacy = math.Min(legacy, g.Fitness)
+	}
+
+	stats, _ := NewGA(corpus, cfg).Evaluate(context.Background())
+	if stats.Best != legacy {
+		t.Fatalf("the first generation best is %f, not the legacy %f", stats.Best, legacy)
+	}
+}
+
+func TestValidationWindow(t *testing.T) {
+	cfg := testConfig()
+	cfg.Window, cfg.ValidationWindow, cfg.ValidationEvery = 80, Span{Offset: 80, Length: 80}, 2
+	ga := NewGA(testCorpus, cfg)
+	for generation := 0; generation < 3; generation++ {
+		stats, _ := ga.Evaluate(context.Background())
+		if stats.Validated != (generation%2 == 0) {
+			t.Fatalf("generation %d is validated: %t", generation, stats.Validated)
+		}
+		if stats.Validated {
+			if stats.Validation <= 0 || stats.Validation == stats.Best {
+				t.Fatalf("the validation fitness is %f and the training fitness %f", stats.Validation, stats.Best)
+			}
+			expected := ga.Genomes[0].Tokenize(ga.Corpus, testCorpus[80:])
+			expected.ComputeFitness(testCorpus[80:], cfg.Fitness)
+			if stats.Validation != expected.Fitness {
+				t.Fatalf("the validation fitness is %f, not %f", stats.Validation, expected.Fitness)
+			}
+		}
+		ga.Breed()
+	}
+}
+
+func TestOperators(t *testing.T) {
+	if _, err := ParseOperators("mutate,cross"); err == nil {
+		t.Fatal("an unknown operator was parsed")
+	}
+	operators, err := ParseOperators("mutate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adaptive, r := NewAdaptiveOperators(.5, operators...), rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if operator := adaptive.Select(r); operator != OperatorMutate {
+			t.Fatalf("the adaptive selector selected %s", operator)
+		}
+	}
+	cfg := testConfig()
+	cfg.Operators = operators
+	ga := NewGA(testCorpus, cfg)
+	for generation := 0; generation < 3; generation++ {
+		ga.Evaluate(context.Background())
+		parents := make([]Genome, len(ga.Genomes))
+		for i := range ga.Genomes {
+			parents[i] = ga.Genomes[i].Copy()
+		}
+		mutations := MutationCount(ga.Generation, len(ga.Corpus), cfg.MutationStart, cfg.MutationEnd, cfg.MutationDecay)
+		ga.Breed()
+		for _, child := range ga.Genomes[len(parents):] {
+			if child.Operator != OperatorMutate {
+				t.Fatalf("a child was produced by %s", child.Operator)
+			}
+			// a mutation nudges a token by one, so a child is within mutations of its parent
+			mutated := false
+			for _, parent := range parents {
+				distance := int64(0)
+				for i, token := range child.Tokens {
+					delta := token - parent.Tokens[i]
+					if delta < 0 {
+						delta = -delta
+					}
+					distance += delta
+				}
+				mutated = mutated || distance <= int64(mutations)
+			}
+			if !mutated {
+				t.Fatalf("a child in generation %d isn't a mutation of a parent", generation)
+			}
+		}
+	}
+}
+
+func TestRunTiles(t *testing.T) {
+	cfg := testConfig()
+	cfg.Window = 80
+	tiles := Tiles(testCorpus, cfg.Window)
+	if len(tiles) != 2 || len(tiles[0])+len(tiles[1]) != len(testCorpus) {
+		t.Fatalf("%d tiles for a corpus of %d bytes", len(tiles), len(testCorpus))
+	}
+	best := RunTiles(testCorpus, cfg)
+	if len(best) != len(tiles) {
+		t.Fatalf("%d genomes for %d tiles", len(best), len(tiles))
+	}
+	for i, g := range best {
+		if err := g.Validate(len(tiles[i])); err != nil {
+			t.Fatalf("tile %d: %v", i, err)
+		}
+	}
+
+	seed := best[0].Resize(len(tiles[1]))
+	seed.ComputeFitness(tiles[1], cfg.Fitness)
+	if best[1].Fitness > seed.Fitness {
+		t.Fatalf("the second tile is worse than its seed: %f and %f", best[1].Fitness, seed.Fitness)
+	}
+	cfg.Genomes = []Genome{seed}
+	if g := RunGA(tiles[1], cfg); g.Fitness != best[1].Fitness || g.Distance(best[1]) != 0 {
+		t.Fatal("the second tile wasn't seeded with the best genome of the first")
+	}
+
+	// the tiles are run in order, and a run that returns false stops the tiling
+	cfg.Genomes = nil
+	calls := 0
+	stopped := RunTilesFunc(tiles, cfg, func(tile int, ga *GA) bool {
+		if tile != calls || len(ga.Corpus) != len(tiles[tile]) {
+			t.Fatalf("call %d runs tile %d of %d bytes", calls, tile, len(ga.Corpus))
+		}
+		calls++
+		ga.Run(context.Background())
+		return false
+	})
+	if calls != 1 || len(stopped) != 1 || stopped[0].Distance(best[0]) != 0 {
+		t.Fatalf("the tiling runs %d tiles after the first one stops it", calls)
+	}
+}
+
+func TestElitism(t *testing.T) {
+	cfg := testConfig()
+	cfg.Elite = 2
+	ga, best := NewGA(testCorpus, cfg), math.MaxFloat64
+	for generation := 0; generation < 6; generation++ {
+		stats, _ := ga.Evaluate(context.Background())
+		if stats.Best > best {
+			t.Fatalf("the best fitness increased from %f to %f in generation %d", best, stats.Best, generation)
+		}
+		best = stats.Best
+		ga.Breed()
+		if len(ga.Genomes) != cfg.Population {
+			t.Fatalf("generation %d has %d genomes, not %d", generation+1, len(ga.Genomes), cfg.Population)
+		}
+	}
+}
+
+func TestElite(t *testing.T) {
+	cfg := testConfig()
+	cfg.Elite = 3
+	ga := NewGA(testCorpus, cfg)
+	for generation := 0; generation < 3; generation++ {
+		ga.Evaluate(context.Background())
+		elite := make([]Genome, cfg.Elite)
+		for i := range elite {
+			elite[i] = ga.Genomes[i].Copy()
+		}
+		ga.Breed()
+		for i, g := range ga.Genomes {
+			if i < cfg.Elite {
+				if g.Operator != OperatorNone || g.Distance(elite[i]) != 0 {
+					t.Fatalf("elite genome %d changed in generation %d", i, generation)
+				}
+				continue
+			}
+			if g.Operator == OperatorNone {
+				t.Fatalf("genome %d of generation %d isn't a child", i, generation+1)
+			}
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxGenerations = 5
+	ga := NewGA(testCorpus, cfg)
+	best := ga.Run(context.Background())
+	if len(ga.History) != cfg.MaxGenerations {
+		t.Fatalf("the history has %d entries for %d generations", len(ga.History), cfg.MaxGenerations)
+	}
+	tokens := make(map[int64]bool)
+	for _, token := range best.Tokens {
+		tokens[token] = true
+	}
+	if last := ga.History[len(ga.History)-1]; last != len(tokens) {
+		t.Fatalf("the last entry of the history is %d, not the %d tokens of the best genome", last, len(tokens))
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	cfg := testConfig()
+	cfg.Patience = 100
+	uninterrupted := NewGA(testCorpus, cfg)
+	expected := make([]GenerationStats, 0, 6)
+	for generation := 0; generation < 6; generation++ {
+		stats, _ := uninterrupted.Evaluate(context.Background())
+		expected = append(expected, stats)
+		uninterrupted.Breed()
+	}
+
+	first := NewGA(testCorpus, cfg)
+	for generation := 0; generation < 2; generation++ {
+		first.Evaluate(context.Background())
+		first.Breed()
+	}
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+	checkpoint := first.Checkpoint()
+	if err := checkpoint.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := NewGA(testCorpus, cfg)
+	resumed.Restore(loaded)
+	for generation := 2; generation < 6; generation++ {
+		stats, _ := resumed.Evaluate(context.Background())
+		if stats.Best != expected[generation].Best || stats.Mean != expected[generation].Mean {
+			t.Fatalf("generation %d of the resumed run has best %f and mean %f, not %f and %f", generation,
+				stats.Best, stats.Mean, expected[generation].Best, expected[generation].Mean)
+		}
+		resumed.Breed()
+	}
+	if !reflect.DeepEqual(resumed.History, uninterrupted.History) {
+		t.Fatalf("the resumed history is %v, not %v", resumed.History, uninterrupted.History)
+	}
+	if resumed.best != uninterrupted.best || resumed.stalled != uninterrupted.stalled {
+		t.Fatalf("the resumed run has best %f stalled %d, not %f and %d",
+			resumed.best, resumed.stalled, uninterrupted.best, uninterrupted.stalled)
+	}
+}
+
+func BenchmarkGeneration500(b *testing.B) {
+	cfg := testConfig()
+	cfg.Population, cfg.MaxGenerations, cfg.Workers = 500, 0, 0
+	ga := NewGA(testCorpus, cfg)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ga.Evaluate(context.Background())
+		ga.Breed()
+	}
+	if len(ga.Genomes) > 2*cfg.Population {
+		b.Fatalf("the population grew to %d", len(ga.Genomes))
+	}
+}
+
+func benchmarkEvaluate(b *testing.B, pooled bool) {
+	cfg := testConfig()
+	cfg.Population, cfg.Workers = 1000, 0
+	ga := NewGA(testCorpus, cfg)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if pooled {
+			ga.Evaluate(context.Background())
+			continue
+		}
+		genomes, done := ga.Genomes, make(chan bool, 8)
+		for j := range genomes {
+			go func(j int) {
+				genomes[j].ComputeFitness(ga.Corpus, cfg.Fitness)
+				done <- true
+			}(j)
+		}
+		for range genomes {
+			<-done
+		}
+	}
+}
+
+func BenchmarkEvaluateGoroutines1000(b *testing.B) {
+	benchmarkEvaluate(b, false)
+}
+
+func BenchmarkEvaluatePool1000(b *testing.B) {
+	benchmarkEvaluate(b, true)
+}
+
+func TestRecover(t *testing.T) {
+	cfg := testConfig()
+	if cfg.Strict {
+		t.Fatal("the default evaluation is strict")
+	}
+	ga := NewGA(testCorpus, cfg)
+	ga.Evaluate(context.Background())
+	ga.Breed()
+	// a broken operator produces a child that is longer than the corpus, which panics in Groups
+	broken := &ga.Genomes[len(ga.Genomes)-1]
+	broken.Tokens = append(broken.Copy().Tokens, 0)
+	genomes := ga.Genomes
+	if _, complete := ga.Evaluate(context.Background()); !complete {
+		t.Fatal("the generation with a broken child isn't complete")
+	}
+	// the broken child has the worst fitness so it is sorted last
+	if last := genomes[len(genomes)-1]; len(last.Tokens) == len(testCorpus) || last.Fitness != math.MaxFloat64 {
+		t.Fatalf("the last genome has %d tokens and fitness %f", len(last.Tokens), last.Fitness)
+	}
+	if best := ga.Genomes[0]; best.Validate(len(testCorpus)) != nil || best.Fitness == math.MaxFloat64 {
+		t.Fatalf("the broken child is the best genome")
+	}
+}
+
+func TestVocabPenalty(t *testing.T) {
+	// on a short corpus not tokenizing beats a coarse segmentation, unless the vocabulary is penalized
+	coarse, err := GenomeFromSegmentation([]int{34, 68, 102}, len(testCorpus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	distinct := func(penalty float64) int {
+		cfg := testConfig()
+		cfg.MaxGenerations, cfg.Fitness.VocabPenalty = 10, penalty
+		cfg.Genomes = []Genome{BaselineGenome(testCorpus), coarse}
+		best := RunGA(testCorpus, cfg)
+		return len(best.Groups(testCorpus))
+	}
+	free, penalized := distinct(0), distinct(.5)
+	if penalized >= free {
+		t.Fatalf("the penalized genome has %d distinct tokens, the unpenalized genome has %d", penalized, free)
+	}
+}
+
+func TestPatience(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxGenerations, cfg.Patience, cfg.Epsilon = 0, 3, .05
+	ga, best := NewGA(testCorpus, cfg), []float64{}
+	for generations := 0; ; generations++ {
+		if generations == 1000 {
+			t.Fatal("the run doesn't stop once it stalls")
+		}
+		stats, _ := ga.Evaluate(context.Background())
+		best = append(best, stats.Best)
+		if ga.Done() {
+			break
+		}
+		ga.Breed()
+	}
+	// the run stops after Patience generations without an improvement of more than Epsilon
+	last := len(best) - 1 - cfg.Patience
+	if last < 0 {
+		t.Fatalf("the run stops after %d generations", len(best))
+	}
+	record := best[0]
+	for i, fitness := range best[:last+1] {
+		if fitness < record-cfg.Epsilon {
+			record = fitness
+		}
+		if i == last && fitness != record {
+			t.Fatalf("generation %d with fitness %f isn't an improvement on %f", i, fitness, record)
+		}
+	}
+	for i, fitness := range best[last+1:] {
+		if fitness < record-cfg.Epsilon {
+			t.Fatalf("generation %d improves on %f with %f", last+1+i, record, fitness)
+		}
+	}
+}
+
+func TestStableSort(t *testing.T) {
+	order := func() []Genome {
+		// short slices are insertion sorted, which is stable anyway
+		cfg := testConfig()
+		cfg.Population = 50
+		ga := NewGA(testCorpus, cfg)
+		// the population alternates between clones of two genomes, the parents mark their place
+		a, b := ga.Genomes[0], ga.Genomes[1]
+		for i := range ga.Genomes {
+			if i%2 == 0 {
+				ga.Genomes[i] = a.Copy()
+			} else {
+				ga.Genomes[i] = b.Copy()
+			}
+			ga.Genomes[i].Parent = float64(i)
+		}
+		genomes := ga.Genomes
+		ga.Evaluate(context.Background())
+		return genomes
+	}
+	first := order()
+	for i := 1; i < len(first); i++ {
+		if first[i].Fitness == first[i-1].Fitness && first[i].Parent < first[i-1].Parent {
+			t.Fatalf("the clone %v is sorted after the clone %v", first[i-1].Parent, first[i].Parent)
+		}
+	}
+	second := order()
+	for i := range first {
+		if first[i].Parent != second[i].Parent {
+			t.Fatalf("the clone at %d is %v and then %v", i, first[i].Parent, second[i].Parent)
+		}
+	}
+}
+
+func TestSeedGenomes(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	rnd := rand.New(rand.NewSource(2))
+	seeds := []Genome{words(t, testCorpus), NewGenome(rnd, len(testCorpus), true)}
+	data, err := json.Marshal([]*Genome{&seeds[0], &seeds[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "seeds.json")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGenomes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig()
+	cfg.Genomes = loaded
+	ga := NewGA(testCorpus, cfg)
+	if len(ga.Genomes) != cfg.Population {
+		t.Fatalf("the population has %d genomes, not %d", len(ga.Genomes), cfg.Population)
+	}
+	// generation zero is the seeds and then random genomes
+	for i, g := range ga.Genomes {
+		seeded := i < len(seeds) && reflect.DeepEqual(g.Tokens, seeds[i].Tokens)
+		if (i < len(seeds)) != seeded {
+			t.Fatalf("genome %d of generation zero is seeded %t", i, seeded)
+		}
+		if err := g.Validate(len(testCorpus)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSelectionPool(t *testing.T) {
+	for _, pool := range []int{1, 3, 0} {
+		cfg := testConfig()
+		cfg.SelectionPool, cfg.Operators = pool, []Operator{OperatorMutate}
+		ga := NewGA(testCorpus, cfg)
+		ga.Evaluate(context.Background())
+		// the fitness of each survivor is its rank, so the parent of a child is the rank it came from
+		for i := range ga.Genomes {
+			ga.Genomes[i].Fitness = float64(i)
+		}
+		bound := SelectionBound(len(ga.Genomes), pool)
+		if pool == 0 && bound != len(ga.Genomes) {
+			t.Fatalf("a pool of 0 is %d of %d genomes", bound, len(ga.Genomes))
+		}
+		for i := 0; i < 10; i++ {
+			ga.Breed()
+			for _, child := range ga.Genomes[ga.elite:] {
+				if child.Parent >= float64(bound) {
+					t.Fatalf("a parent of rank %v is outside of a pool of %d", child.Parent, bound)
+				}
+			}
+			ga.Genomes = ga.Genomes[:ga.elite]
+		}
+	}
+}
+
+func TestMemLimit(t *testing.T) {
+	size := func(ga *GA) int {
+		entries := 0
+		for _, genomes := range ga.cache {
+			entries += len(genomes)
+		}
+		return entries
+	}
+	ga := NewGA(testCorpus, testConfig())
+	ga.Evaluate(context.Background())
+	ga.Breed()
+	ga.Evaluate(context.Background())
+	cached := size(ga)
+	if cached == 0 {
+		t.Fatal("the scored genomes aren't cached")
+	}
+	// a cached fitness is the one the genome scores from scratch
+	for _, genomes := range ga.cache {
+		for _, g := range genomes {
+			fitness := g.Fitness
+			g.ComputeFitness(ga.Corpus, ga.Config.Fitness)
+			if g.Fitness != fitness {
+				t.Fatalf("cached fitness %f, computed %f", fitness, g.Fitness)
+			}
+		}
+	}
+
+	// any heap is over a limit of a byte
+	ga.Config.MemLimit = 1
+	ga.Breed()
+	ga.Evaluate(context.Background())
+	if trimmed := size(ga); trimmed >= cached {
+		t.Fatalf("the cache holds %d genomes over the memory limit, %d before it", trimmed, cached)
+	}
+}
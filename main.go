@@ -5,40 +5,124 @@
 package main
 
 import (
-	//"bytes"
-	//"compress/gzip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
-	//"math"
+	"math"
 	"math/rand"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pointlander/token/cdf16"
+	"github.com/pointlander/token/tokenizer"
 )
 
-// Size is the size of the population
-const Size = 100
+// SelectionBound is the number of top genomes parents can be drawn from given the survivors and the
+// size of the selection pool, a pool that isn't positive is all of the survivors
+func SelectionBound(survivors, pool int) int {
+	if pool <= 0 || survivors < pool {
+		return survivors
+	}
+	return pool
+}
 
 // Curie is the wiki on curie
 var Curie []byte
 
+// LoadInput reads at most window bytes from the reader, or all of it if window isn't positive
+func LoadInput(reader io.Reader, window int) ([]byte, error) {
+	if window > 0 {
+		reader = io.LimitReader(reader, int64(window))
+	}
+	return ioutil.ReadAll(reader)
+}
+
+// SetCorpus sets the corpus Print and MarshalJSON segment genomes with, the GA takes its corpus as
+// an argument instead
+func SetCorpus(corpus []byte) {
+	Curie = corpus
+}
+
+// FitnessConfig are the parameters of the fitness function
+type FitnessConfig struct {
+	// Objective is the objective the fitness measures
+	Objective Objective
+	// Depth is the depth of the context models that measure complexity
+	Depth int
+	// VocabPenalty is the fitness penalty per distinct token, it favors compact vocabularies
+	VocabPenalty float64
+	// ContiguityWeight weights the penalty for tokens that are scattered over several runs
+	ContiguityWeight float64
+	// Varint encodes the token ids of the stream term as varints instead of 8 little endian bytes.
+	// The high order zero bytes of the fixed width encoding are very predictable, so with varints the
+	// stream term is larger and fitness values are not comparable across the two settings.
+	Varint bool
+}
+
+// DefaultFitnessConfig returns the default parameters of the fitness function
+func DefaultFitnessConfig() FitnessConfig {
+	return FitnessConfig{
+		Objective: ObjectiveComplexity,
+		Depth:     cdf16.CDF16Depth,
+	}
+}
+
+// AppendToken appends the stream term encoding of a token id to buffer
+func AppendToken(buffer []byte, token int64, varint bool) []byte {
+	var output [binary.MaxVarintLen64]byte
+	if varint {
+		n := binary.PutUvarint(output[:], uint64(token))
+		return append(buffer, output[:n]...)
+	}
+	binary.LittleEndian.PutUint64(output[:], uint64(token))
+	return append(buffer, output[:8]...)
+}
+
+// BlockResetMaximum is the longest block of tokens a block reset mutation re-randomizes
+const BlockResetMaximum = 32
+
 // Genome is a token genome
 type Genome struct {
 	Tokens  []int64
 	Fitness float64
+	// Operator is the operator that produced the genome and Parent the fitness of its best parent
+	Operator Operator
+	Parent   float64
 }
 
-// NewGenome creates a new genome
-func NewGenome() Genome {
-	length := len(Curie)
+// NewGenome creates a new genome for a corpus of the given length. The runs get random token ids,
+// or dense incrementing ids starting at 0 if canonical is set
+func NewGenome(r *rand.Rand, length int, canonical bool) Genome {
 	tokens := make([]int64, length)
-	token := int64(rand.Intn(length))
+	token := int64(0)
+	if !canonical {
+		token = int64(r.Intn(length))
+	}
 	for i := range tokens {
 		tokens[i] = token
-		if rand.Intn(8) == 0 {
-			token = int64(rand.Intn(length))
+		if r.Intn(8) == 0 {
+			if canonical {
+				token++
+				continue
+			}
+			token = int64(r.Intn(length))
 		}
 	}
 	return Genome{
@@ -46,35 +130,195 @@ func NewGenome() Genome {
 	}
 }
 
-// ComputeFitness computes the fitness of the genome
-func (g *Genome) ComputeFitness() {
+// Groups groups the bytes of the corpus by token
+func (g *Genome) Groups(corpus []byte) map[int64][]byte {
 	tokens := make(map[int64][]byte)
 	for i, token := range g.Tokens {
 		t := tokens[token]
 		if t == nil {
 			t = make([]byte, 0, 8)
 		}
-		t = append(t, Curie[i])
+		t = append(t, corpus[i])
 		tokens[token] = t
 	}
+	return tokens
+}
 
-	fitness := 0.0
-	for _, set := range tokens {
-		complexity := NewComplexity(CDF16Depth)
-		fitness += float64(complexity.Complexity(set))
+// Keys returns the tokens of the groups in ascending order, summing over the groups in this order
+// keeps fitness bit for bit reproducible
+func Keys(groups map[int64][]byte) []int64 {
+	keys := make([]int64, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// Scatter is the mean number of runs each token occupies beyond its first, it is 0 when every
+// token is a single contiguous run
+func (g *Genome) Scatter() float64 {
+	if len(g.Tokens) == 0 {
+		return 0
+	}
+	runs := make(map[int64]int)
+	for i, token := range g.Tokens {
+		if i == 0 || g.Tokens[i-1] != token {
+			runs[token]++
+		}
+	}
+	extra := 0
+	for _, count := range runs {
+		extra += count - 1
 	}
-	fitness /= float64(len(tokens))
+	return float64(extra) / float64(len(runs))
+}
 
-	complexity := NewComplexity(CDF16Depth)
-	output := make([]byte, 8)
+// Boundaries returns the positions where the token changes
+func (g *Genome) Boundaries() []int {
+	boundaries := make([]int, 0, 8)
+	for i := 1; i < len(g.Tokens); i++ {
+		if g.Tokens[i] != g.Tokens[i-1] {
+			boundaries = append(boundaries, i)
+		}
+	}
+	return boundaries
+}
+
+// Segments returns the contiguous runs of the corpus that share a token
+func (g *Genome) Segments(corpus []byte) [][]byte {
+	if len(g.Tokens) == 0 {
+		return nil
+	}
+	segments, begin := make([][]byte, 0, 8), 0
+	for _, boundary := range g.Boundaries() {
+		segments = append(segments, corpus[begin:boundary])
+		begin = boundary
+	}
+	return append(segments, corpus[begin:len(g.Tokens)])
+}
+
+// Stream encodes the token ids for the stream term, as varints if varint is set
+func (g *Genome) Stream(varint bool) []byte {
 	buffer := make([]byte, 0, 8)
 	for _, t := range g.Tokens {
-		binary.LittleEndian.PutUint64(output, uint64(t))
-		buffer = append(buffer, output...)
+		buffer = AppendToken(buffer, t, varint)
 	}
-	fitness += float64(complexity.Complexity(buffer))
+	return buffer
+}
+
+// GenomeFromSegmentation creates a genome of the given length with incrementing token ids for
+// each segment, boundaries are the strictly increasing positions where a new segment starts
+func GenomeFromSegmentation(boundaries []int, length int) (Genome, error) {
+	for i, boundary := range boundaries {
+		if boundary < 0 || boundary >= length {
+			return Genome{}, fmt.Errorf("boundary %d at %d is out of range [0, %d)", i, boundary, length)
+		}
+		if i > 0 && boundary <= boundaries[i-1] {
+			return Genome{}, fmt.Errorf("boundary %d at %d is not after %d", i, boundary, boundaries[i-1])
+		}
+	}
+
+	tokens, token := make([]int64, length), int64(0)
+	for i := range tokens {
+		if len(boundaries) > 0 && boundaries[0] == i {
+			if i > 0 {
+				token++
+			}
+			boundaries = boundaries[1:]
+		}
+		tokens[i] = token
+	}
+	return Genome{
+		Tokens: tokens,
+	}, nil
+}
+
+// BaselineGenome creates the genome that doesn't tokenize: each byte value is its own token
+func BaselineGenome(corpus []byte) Genome {
+	tokens := make([]int64, len(corpus))
+	for i, b := range corpus {
+		tokens[i] = int64(b)
+	}
+	return Genome{
+		Tokens: tokens,
+	}
+}
+
+// ComputeFitness computes the fitness of the genome on the corpus
+func (g *Genome) ComputeFitness(corpus []byte, cfg FitnessConfig) {
+	g.ComputeFitnessCtx(context.Background(), corpus, cfg)
+}
+
+// ComputeFitnessCtx computes the fitness of the genome on the corpus, stopping early if the context is
+// done. An approximate fitness is the mean complexity of the token groups scored before the context
+// was done and leaves out the stream term and the penalties, it is zero if no group was
+// scored. The MDL objective is always computed in full.
+//
+// The fitness is the unweighted mean over tokens, so the extremes are degenerate. A genome of a
+// single token is one group holding the whole corpus and a stream that is a single repeated id,
+// which scores lower than not tokenizing at all even though it learns nothing; its fitness is
+// raised to that of BaselineGenome. A genome where every position is its own token needs no
+// special case: each group is a single byte, which has a high complexity, so it doesn't win.
+func (g *Genome) ComputeFitnessCtx(ctx context.Context, corpus []byte, cfg FitnessConfig) (approximate bool) {
+	return g.computeFitness(ctx, corpus, cfg, cdf16.NewComplexity(cfg.Depth))
+}
+
+// computeFitness is ComputeFitnessCtx with a model that is reset and reused for every input of the
+// complexity objective, so that a worker can reuse one model for many genomes
+func (g *Genome) computeFitness(ctx context.Context, corpus []byte, cfg FitnessConfig, model *cdf16.Complexity) (approximate bool) {
+	if cfg.Objective == ObjectiveMDL {
+		g.Fitness = g.MDLFull(corpus, cfg) + cfg.ContiguityWeight*g.Scatter() +
+			cfg.VocabPenalty*float64(len(g.Groups(corpus)))
+		return false
+	}
+	tokens := g.Groups(corpus)
+	fitness, approximate := g.complexity(ctx, tokens, cfg, model)
+	if approximate {
+		g.Fitness = fitness
+		return approximate
+	}
+	if len(tokens) == 1 {
+		// the baseline of a corpus of one repeated byte is also a single token
+		if baseline := BaselineGenome(corpus); len(baseline.Groups(corpus)) > 1 {
+			minimum, _ := baseline.complexity(ctx, baseline.Groups(corpus), cfg, model)
+			fitness = math.Max(fitness, minimum)
+		}
+	}
+	fitness += cfg.ContiguityWeight * g.Scatter()
+	fitness += cfg.VocabPenalty * float64(len(tokens))
 
 	g.Fitness = fitness
+	return approximate
+}
+
+// complexity is the complexity objective without the penalties: the mean complexity of the token
+// groups plus the complexity of the token id stream. The model is reset before each input, so the
+// result is the same as with a new model for each one
+func (g *Genome) complexity(ctx context.Context, tokens map[int64][]byte, cfg FitnessConfig, model *cdf16.Complexity) (fitness float64, approximate bool) {
+	count := 0
+	for _, key := range Keys(tokens) {
+		set := tokens[key]
+		if ctx.Err() != nil {
+			approximate = true
+			break
+		}
+		model.Reset()
+		fitness += float64(model.Complexity(set))
+		count++
+	}
+	if count > 0 {
+		fitness /= float64(count)
+	}
+	if approximate {
+		return fitness, approximate
+	}
+
+	model.Reset()
+	fitness += float64(model.Complexity(g.Stream(cfg.Varint)))
+	return fitness, approximate
 }
 
 // Copy copies a genome
@@ -86,105 +330,954 @@ func (g *Genome) Copy() Genome {
 	}
 }
 
+// Tokenize tokenizes text with the vocabulary of the genome over the corpus it was evolved on, see
+// tokenizer.Tokenizer. The returned genome has a token for each byte of the text: the id of the
+// token that covers it, so its ids aren't bounded by the length of the text
+func (g *Genome) Tokenize(corpus, text []byte) Genome {
+	t := tokenizer.NewTokenizer(g.Segments(corpus))
+	tokens := make([]int64, 0, len(text))
+	for _, id := range t.Encode(text) {
+		length := 1
+		if id >= tokenizer.ByteTokens {
+			length = len(t.Tokens[id-tokenizer.ByteTokens])
+		}
+		for i := 0; i < length; i++ {
+			tokens = append(tokens, id)
+		}
+	}
+	return Genome{
+		Tokens: tokens,
+	}
+}
+
+// Resize returns a copy of the genome for a corpus of the given length. It is truncated, or extended
+// with its last token, and token ids that would be out of range for the new length wrap around
+func (g *Genome) Resize(length int) Genome {
+	tokens := make([]int64, length)
+	copy(tokens, g.Tokens)
+	for i := range tokens {
+		if i >= len(g.Tokens) {
+			tokens[i] = tokens[i-1]
+		}
+		tokens[i] %= int64(length)
+	}
+	return Genome{
+		Tokens: tokens,
+	}
+}
+
+// Mutate returns a copy of the genome with one token nudged by one, clamped to [0, maxToken]
+func (g *Genome) Mutate(r *rand.Rand, maxToken int64) Genome {
+	return g.MutateN(r, maxToken, 1, Mutation{})
+}
+
+// Mutation are the probabilities of the structural mutations of MutateN
+type Mutation struct {
+	// Split is the probability that a mutation splits a run of tokens in two
+	Split float64
+	// Merge is the probability that a mutation merges a run of tokens with the next run
+	Merge float64
+	// BlockReset is the probability that a mutation re-randomizes a block of tokens
+	BlockReset float64
+}
+
+// MutateN returns a copy of the genome with n mutations. A mutation splits a run of tokens with
+// probability m.Split, merges a run with the next with probability m.Merge, re-randomizes a block
+// of tokens with probability m.BlockReset, and otherwise nudges a token by one, clamped to
+// [0, maxToken]
+func (g *Genome) MutateN(r *rand.Rand, maxToken int64, n int, m Mutation) Genome {
+	cp := g.Copy()
+	for i := 0; i < n; i++ {
+		mutate := r.Intn(len(cp.Tokens))
+		if m.Split+m.Merge+m.BlockReset > 0 {
+			x := r.Float64()
+			if x < m.Split {
+				cp.SplitRun(mutate, maxToken)
+				continue
+			} else if x < m.Split+m.Merge {
+				cp.MergeRun(mutate)
+				continue
+			} else if x < m.Split+m.Merge+m.BlockReset {
+				cp.BlockReset(r, mutate, maxToken)
+				continue
+			}
+		}
+		switch r.Intn(2) {
+		case 0:
+			cp.Tokens[mutate]++
+			if cp.Tokens[mutate] > maxToken {
+				cp.Tokens[mutate] = maxToken
+			}
+		case 1:
+			cp.Tokens[mutate]--
+			if cp.Tokens[mutate] < 0 {
+				cp.Tokens[mutate] = 0
+			}
+		}
+	}
+	return cp
+}
+
+// BlockReset re-randomizes a block of up to BlockResetMaximum tokens starting at position i the way
+// NewGenome does: the block starts with a random token id in [0, maxToken] and a new one is drawn
+// with a probability of 1 in 8 at each position
+func (g *Genome) BlockReset(r *rand.Rand, i int, maxToken int64) {
+	end := i + 1 + r.Intn(BlockResetMaximum)
+	if end > len(g.Tokens) {
+		end = len(g.Tokens)
+	}
+	token := r.Int63n(maxToken + 1)
+	for j := i; j < end; j++ {
+		g.Tokens[j] = token
+		if r.Intn(8) == 0 {
+			token = r.Int63n(maxToken + 1)
+		}
+	}
+}
+
+// Run returns the bounds [start, end) of the run of equal tokens that contains position i
+func (g *Genome) Run(i int) (start, end int) {
+	start, end = i, i+1
+	for start > 0 && g.Tokens[start-1] == g.Tokens[i] {
+		start--
+	}
+	for end < len(g.Tokens) && g.Tokens[end] == g.Tokens[i] {
+		end++
+	}
+	return start, end
+}
+
+// SplitRun assigns the smallest unused token id in [0, maxToken] to the second half of the run that
+// contains position i. Runs of one token and genomes with no unused ids are left unchanged
+func (g *Genome) SplitRun(i int, maxToken int64) {
+	start, end := g.Run(i)
+	if end-start < 2 {
+		return
+	}
+	used := make(map[int64]bool)
+	for _, token := range g.Tokens {
+		used[token] = true
+	}
+	fresh := int64(0)
+	for used[fresh] {
+		fresh++
+	}
+	if fresh > maxToken {
+		return
+	}
+	for j := start + (end-start)/2; j < end; j++ {
+		g.Tokens[j] = fresh
+	}
+}
+
+// MergeRun gives the run after the run that contains position i the token id of that run
+func (g *Genome) MergeRun(i int) {
+	_, end := g.Run(i)
+	if end == len(g.Tokens) {
+		return
+	}
+	_, next := g.Run(end)
+	for j := end; j < next; j++ {
+		g.Tokens[j] = g.Tokens[i]
+	}
+}
+
+// MutationCount is the number of tokens to mutate in a generation. It starts at the fraction
+// start of the genome length and decays exponentially with time constant decay generations toward
+// end tokens, it is never less than 1
+func MutationCount(generation, length int, start, end, decay float64) int {
+	initial := start * float64(length)
+	if initial < end || decay <= 0 {
+		initial = end
+	}
+	count := int(math.Round(end + (initial-end)*math.Exp(-float64(generation)/decay)))
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// Validate checks that the genome has a token for each byte of a corpus of corpusLen bytes, and that
+// every token id is in [0, corpusLen-1] like the ids the mutations produce
+func (g *Genome) Validate(corpusLen int) error {
+	if len(g.Tokens) != corpusLen {
+		return fmt.Errorf("the genome has %d tokens but the corpus has %d bytes", len(g.Tokens), corpusLen)
+	}
+	for i, token := range g.Tokens {
+		if token < 0 || token >= int64(corpusLen) {
+			return fmt.Errorf("token %d at %d is out of range [0, %d)", token, i, corpusLen)
+		}
+	}
+	return nil
+}
+
+// Save writes the genome to a file with gob
+func (g *Genome) Save(path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(out).Encode(g)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// LoadGenome reads a genome written by Save or as JSON
+func LoadGenome(path string) (Genome, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return Genome{}, err
+	}
+	defer in.Close()
+	var g Genome
+	reader := bufio.NewReader(in)
+	if first, err := reader.Peek(1); err == nil && first[0] == '{' {
+		err = json.NewDecoder(reader).Decode(&g)
+		return g, err
+	}
+	err = gob.NewDecoder(reader).Decode(&g)
+	return g, err
+}
+
+// LoadGenomes reads several genomes from a file: a JSON array, a sequence of JSON objects, or a
+// sequence of gob encoded genomes like Save writes
+func LoadGenomes(path string) ([]Genome, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	reader := bufio.NewReader(in)
+	var genomes []Genome
+	if first, err := reader.Peek(1); err == nil && first[0] == '[' {
+		err = json.NewDecoder(reader).Decode(&genomes)
+		return genomes, err
+	} else if err == nil && first[0] == '{' {
+		decoder := json.NewDecoder(reader)
+		for decoder.More() {
+			var g Genome
+			if err := decoder.Decode(&g); err != nil {
+				return nil, err
+			}
+			genomes = append(genomes, g)
+		}
+		return genomes, nil
+	}
+	decoder := gob.NewDecoder(reader)
+	for {
+		var g Genome
+		err := decoder.Decode(&g)
+		if err == io.EOF {
+			return genomes, nil
+		} else if err != nil {
+			return nil, err
+		}
+		genomes = append(genomes, g)
+	}
+}
+
+// genomeJSON is the JSON form of a genome
+type genomeJSON struct {
+	Fitness  float64  `json:"fitness"`
+	Tokens   []int64  `json:"tokens"`
+	Encoding string   `json:"encoding,omitempty"`
+	Segments []string `json:"segments,omitempty"`
+}
+
+// MarshalJSON encodes the fitness, the tokens and the segments of the corpus. The segments are
+// strings if they are all valid UTF-8 and base64 otherwise, as given by the encoding field
+func (g *Genome) MarshalJSON() ([]byte, error) {
+	output := genomeJSON{
+		Fitness: g.Fitness,
+		Tokens:  g.Tokens,
+	}
+	if len(g.Tokens) > 0 && len(Curie) >= len(g.Tokens) {
+		segments, valid := g.Segments(Curie), true
+		for _, segment := range segments {
+			valid = valid && utf8.Valid(segment)
+		}
+		output.Encoding = "utf8"
+		if !valid {
+			output.Encoding = "base64"
+		}
+		for _, segment := range segments {
+			if valid {
+				output.Segments = append(output.Segments, string(segment))
+				continue
+			}
+			output.Segments = append(output.Segments, base64.StdEncoding.EncodeToString(segment))
+		}
+	}
+	return json.Marshal(output)
+}
+
+// UnmarshalJSON decodes the fitness and the tokens, the segments are derived from the corpus
+func (g *Genome) UnmarshalJSON(data []byte) error {
+	var input genomeJSON
+	err := json.Unmarshal(data, &input)
+	if err != nil {
+		return err
+	}
+	g.Fitness, g.Tokens = input.Fitness, input.Tokens
+	return nil
+}
+
 // Print prints the genome
 func (g *Genome) Print() {
-	tokens := make(map[int64][]byte)
-	for i, token := range g.Tokens {
-		t := tokens[token]
-		if t == nil {
-			t = make([]byte, 0, 8)
+	tokens := g.Groups(Curie)
+
+	for _, key := range Keys(tokens) {
+		fmt.Println(key, string(tokens[key]))
+	}
+}
+
+// GzipRatio is the size of the input compressed with gzip over the size of the input
+func GzipRatio(input []byte) (float64, error) {
+	var buffer bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+	if err != nil {
+		return 0, err
+	}
+	_, err = writer.Write(input)
+	if err != nil {
+		return 0, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return 0, err
+	}
+	if len(input) == 0 {
+		return 1, nil
+	}
+	return float64(buffer.Len()) / float64(len(input)), nil
+}
+
+// TilePath numbers a path for a tile by inserting the tile number before its extension, an empty
+// path stays empty
+func TilePath(path string, tile int) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), tile, ext)
+}
+
+// SaveTopK writes the first n genomes of a sorted population to dir as numbered JSON files
+func SaveTopK(genomes []Genome, n int, dir string) error {
+	if n > len(genomes) {
+		n = len(genomes)
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		data, err := json.Marshal(&genomes[i])
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.json", i)), data, 0644)
+		if err != nil {
+			return err
 		}
-		t = append(t, Curie[i])
-		tokens[token] = t
 	}
+	return nil
+}
+
+// MetricsHeader is the header of the per generation metrics CSV
+var MetricsHeader = []string{"generation", "best_fitness", "mean_fitness", "distinct_tokens", "elapsed_ms", "diversity", "compression_ratio"}
+
+// WriteMetrics writes the metrics of a generation and the compression ratio of its best genome as a
+// row of the metrics CSV, the row is flushed so that a killed run keeps its history
+func WriteMetrics(metrics *csv.Writer, stats GenerationStats, ratio float64) error {
+	metrics.Write([]string{
+		strconv.Itoa(stats.Generation),
+		strconv.FormatFloat(stats.Best, 'g', -1, 64),
+		strconv.FormatFloat(stats.Mean, 'g', -1, 64),
+		strconv.Itoa(stats.Distinct),
+		strconv.FormatInt(stats.Elapsed.Milliseconds(), 10),
+		strconv.FormatFloat(stats.Diversity, 'g', -1, 64),
+		strconv.FormatFloat(ratio, 'g', -1, 64),
+	})
+	metrics.Flush()
+	return metrics.Error()
+}
 
-	for key, value := range tokens {
-		fmt.Println(key, string(value))
+// Sparkline renders a series as a line of block characters at most width wide
+func Sparkline(series []int, width int) string {
+	if len(series) == 0 || width <= 0 {
+		return ""
+	}
+	if len(series) < width {
+		width = len(series)
+	}
+	buckets := make([]float64, width)
+	for i := range buckets {
+		begin, end := i*len(series)/width, (i+1)*len(series)/width
+		sum := 0
+		for _, value := range series[begin:end] {
+			sum += value
+		}
+		buckets[i] = float64(sum) / float64(end-begin)
+	}
+	min, max := buckets[0], buckets[0]
+	for _, value := range buckets {
+		if value < min {
+			min = value
+		}
+		if value > max {
+			max = value
+		}
+	}
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	line := make([]rune, width)
+	for i, value := range buckets {
+		index := 0
+		if max > min {
+			index = int((value - min) / (max - min) * float64(len(blocks)-1))
+		}
+		line[i] = blocks[index]
+	}
+	return string(line)
+}
+
+// defaults are the parameters of the genetic algorithm the flags default to
+var defaults = DefaultGAConfig()
+
+var (
+	// FlagInput is the file to tokenize
+	FlagInput = flag.String("input", "", "the file to tokenize, - for stdin. "+
+		"If unset stdin is read when it isn't a terminal and curie.wiki otherwise")
+	// FlagWindow is the number of leading bytes of the input to tokenize
+	FlagWindow = flag.Int("window", defaults.Window, "the number of leading bytes of the input to tokenize, 0 for all of it")
+	// FlagTile evolves over every window of the input in order instead of only the first
+	FlagTile = flag.Bool("tile", false, "evolve over consecutive windows of the whole input, each seeded with the best genome of the last")
+	// FlagValidationOffset is the offset of the validation window in the input
+	FlagValidationOffset = flag.Int("validation-offset", 0, "the offset of the held out validation window in the input")
+	// FlagValidationLength is the length of the validation window, 0 disables validation
+	FlagValidationLength = flag.Int("validation-length", 0, "the length of the held out validation window, 0 for no validation")
+	// FlagValidationEvery is the number of generations between validations
+	FlagValidationEvery = flag.Int("validation-every", defaults.ValidationEvery, "the number of generations between validations")
+	// FlagOperators are the active operators
+	FlagOperators = flag.String("operators", "mutate,swap,copy", "the comma separated active operators: mutate, swap and copy")
+	// FlagPopulation is the size of the population
+	FlagPopulation = flag.Int("population", defaults.Population, "the size of the population")
+	// FlagSeed is the random seed
+	FlagSeed = flag.Int64("seed", 0, "the random seed, 0 for a time based seed. "+
+		"Fitness evaluation runs in goroutines but doesn't use rand, so an explicit seed reproduces a run exactly")
+	// FlagOut is the file the best genome is saved to on exit
+	FlagOut = flag.String("out", "", "save the best genome to this file on exit")
+	// FlagFormat is the output format of the best genome
+	FlagFormat = flag.String("format", "text", "the output format of the best genome: text or json, json is written to -out if given")
+	// FlagCanonicalInit numbers the runs of the random genomes with dense incrementing ids
+	FlagCanonicalInit = flag.Bool("canonical-init", defaults.CanonicalInit, "number the runs of the initial random genomes 0, 1, 2... "+
+		"instead of with random ids")
+	// FlagResume is a saved genome that seeds the initial population
+	FlagResume = flag.String("resume", "", "seed the initial population with a saved genome")
+	// FlagSeedGenomes is a file of saved genomes that seed the initial population
+	FlagSeedGenomes = flag.String("seed-genomes", "", "seed the initial population with the genomes in this file: "+
+		"a JSON array, JSON objects or gob encoded genomes")
+	// FlagCheckpoint is the file the run is checkpointed to and restored from
+	FlagCheckpoint = flag.String("checkpoint", "", "checkpoint the run to this file and continue from it if it exists")
+	// FlagCheckpointEvery is the number of generations between checkpoints
+	FlagCheckpointEvery = flag.Int("checkpoint-every", 10, "the number of generations between checkpoints")
+	// FlagElite is the number of best genomes copied unchanged into the next generation
+	FlagElite = flag.Int("elite", defaults.Elite, "the number of best genomes copied unchanged into the next generation, "+
+		"the rest is filled with children. 0 keeps the whole population and adds as many children")
+	// FlagSelection is the parent selection scheme
+	FlagSelection = flag.String("selection", defaults.Selection, "the parent selection scheme: top10 or tournament")
+	// FlagSelectionPool is the number of best genomes top10 selection draws parents from
+	FlagSelectionPool = flag.Int("selection-pool", defaults.SelectionPool, "the number of best genomes top10 selection draws parents from, 0 for all of them")
+	// FlagTournamentSize is the number of genomes in a selection tournament
+	FlagTournamentSize = flag.Int("tournament-size", defaults.TournamentSize, "the number of genomes in a selection tournament")
+	// FlagMutationStart is the fraction of the genome mutated per offspring in the first generation
+	FlagMutationStart = flag.Float64("mutation-start", defaults.MutationStart, "the fraction of the genome mutated per offspring in the first generation")
+	// FlagMutationEnd is the number of tokens mutated per offspring the schedule decays toward
+	FlagMutationEnd = flag.Float64("mutation-end", defaults.MutationEnd, "the number of tokens mutated per offspring the schedule decays toward")
+	// FlagMutationDecay is the time constant of the mutation schedule in generations
+	FlagMutationDecay = flag.Float64("mutation-decay", defaults.MutationDecay, "the time constant of the mutation schedule in generations")
+	// FlagMaxGenerations is the number of generations after which the run stops
+	FlagMaxGenerations = flag.Int("max-generations", defaults.MaxGenerations, "stop after this many generations, 0 for no limit")
+	// FlagPatience is the number of generations without improvement after which the run stops
+	FlagPatience = flag.Int("patience", defaults.Patience, "stop after this many generations without improvement, 0 to never stop")
+	// FlagEpsilon is the smallest fitness decrease that counts as an improvement
+	FlagEpsilon = flag.Float64("epsilon", defaults.Epsilon, "the smallest fitness decrease that counts as an improvement")
+	// FlagQuiet suppresses the per generation status line
+	FlagQuiet = flag.Bool("quiet", false, "don't print the per generation status line")
+	// FlagLog is the CSV file per generation metrics are written to
+	FlagLog = flag.String("log", "", "write per generation metrics to this CSV file")
+	// FlagHistory prints the distinct token count of every generation on exit
+	FlagHistory = flag.Bool("history", false, "print the distinct token count of every generation on exit")
+	// FlagTopK is the number of best genomes saved on exit and at checkpoints
+	FlagTopK = flag.Int("top-k", 0, "save the best N genomes on exit and at checkpoints")
+	// FlagTopKDir is the directory the best genomes are saved to
+	FlagTopKDir = flag.String("top-k-dir", "top", "the directory the best genomes are saved to")
+	// FlagDeltas reports the mean fitness improvement of each operator
+	FlagDeltas = flag.Bool("deltas", defaults.Deltas, "track the fitness improvement of each operator")
+	// FlagAdaptive shifts operator selection toward operators that recently improved fitness
+	FlagAdaptive = flag.Bool("adaptive", defaults.Adaptive, "adapt operator selection probabilities to recent improvements")
+	// FlagAdaptiveRate is the learning rate of adaptive operator selection
+	FlagAdaptiveRate = flag.Float64("adaptive-rate", defaults.AdaptiveRate, "the learning rate of adaptive operator selection")
+	// FlagCrossover is the crossover operator
+	FlagCrossover = flag.String("crossover", defaults.Crossover, "the crossover operator: point1, point2 or uniform")
+	// FlagUniformRate is the probability that uniform crossover exchanges a token
+	FlagUniformRate = flag.Float64("uniform-rate", defaults.UniformRate, "the probability that uniform crossover exchanges a token")
+	// FlagShareRadius is the token distance within which genomes share fitness
+	FlagShareRadius = flag.Int("share-radius", defaults.ShareRadius, "penalize the fitness of genomes with neighbors within this many differing tokens, "+
+		"reported fitness includes the penalty. 0 disables fitness sharing, which is O(n^2) in the population")
+	// FlagShareStrength is the strength of the fitness sharing penalty
+	FlagShareStrength = flag.Float64("share-strength", defaults.ShareStrength, "the fitness sharing penalty per neighbor")
+	// FlagContiguity is the weight of the penalty for scattered tokens
+	FlagContiguity = flag.Float64("contiguity", defaults.Fitness.ContiguityWeight, "the weight of the penalty for tokens scattered over several runs")
+	// FlagSplit is the probability that a mutation splits a run of tokens
+	FlagSplit = flag.Float64("split", defaults.Mutation.Split, "the probability that a mutation splits a run of tokens in two")
+	// FlagMerge is the probability that a mutation merges adjacent runs of tokens
+	FlagMerge = flag.Float64("merge", defaults.Mutation.Merge, "the probability that a mutation merges a run of tokens with the next run")
+	// FlagBlockReset is the probability that a mutation re-randomizes a block of tokens
+	FlagBlockReset = flag.Float64("block-reset", defaults.Mutation.BlockReset, "the probability that a mutation re-randomizes a block of tokens")
+	// FlagCompareBaseline reports the improvement of the best genome over no tokenization on exit
+	FlagCompareBaseline = flag.Bool("compare-baseline", false, "report the improvement over no tokenization on exit")
+	// FlagBaseline is a standard compressor the best genome is compared to
+	FlagBaseline = flag.String("baseline", "", "print the compression ratio of a standard compressor with the best genome: gzip")
+	// FlagVocabPenalty is the fitness penalty per distinct token
+	FlagVocabPenalty = flag.Float64("vocab-penalty", defaults.Fitness.VocabPenalty, "the fitness penalty per distinct token")
+	// FlagVarint encodes the token ids of the stream term as varints
+	FlagVarint = flag.Bool("varint", defaults.Fitness.Varint, "encode the token ids of the stream term as varints instead of 8 bytes, "+
+		"the stream term is larger so fitness values aren't comparable with the fixed width encoding")
+	// FlagStrict crashes on a panic in fitness evaluation. Without it the panic is logged and the
+	// genome gets the worst fitness so it is selected out, which protects long runs but masks bugs
+	FlagStrict = flag.Bool("strict", defaults.Strict, "crash if fitness evaluation panics")
+	// FlagDedup replaces duplicate genomes with random ones
+	FlagDedup = flag.Bool("dedup", defaults.Dedup, "replace duplicate genomes with random ones after each generation")
+	// FlagWorkers is the number of goroutines that compute fitness
+	FlagWorkers = flag.Int("workers", defaults.Workers, "the number of goroutines that compute fitness, 0 for one per CPU")
+	// FlagMemLimit is the heap size above which the fitness cache and the node pool are trimmed
+	FlagMemLimit = flag.Uint64("mem-limit", defaults.MemLimit, "the heap size in bytes above which the fitness cache and the pooled context nodes are dropped, 0 for no limit")
+	// FlagStats reports the size of the context tree of a model trained on the input and exits
+	FlagStats = flag.Bool("stats", false, "print the size of the context tree of a model trained on the input and exit")
+	// FlagObjective is the fitness objective
+	FlagObjective = flag.String("objective", "complexity", "the fitness objective: complexity or mdl")
+)
+
+// ComplexityCommand writes the bits per byte of a file under the CDF16 model to output, an empty file
+// is 0 bits per byte
+func ComplexityCommand(args []string, output io.Writer) error {
+	flags := flag.NewFlagSet("complexity", flag.ContinueOnError)
+	input := flags.String("input", "", "the file to measure")
+	depth := flags.Int("depth", cdf16.CDF16Depth, "the depth of the context tree")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(*input)
+	if err != nil {
+		return err
+	}
+	complexity := cdf16.NewComplexity(*depth)
+	_, err = fmt.Fprintln(output, complexity.Complexity(data))
+	return err
+}
+
+// TokenizeCommand tokenizes a file with the vocabulary of a saved genome and prints the token ids
+func TokenizeCommand(args []string) {
+	flags := flag.NewFlagSet("tokenize", flag.ExitOnError)
+	genome := flags.String("genome", "", "the saved genome")
+	corpus := flags.String("corpus", "curie.wiki", "the file the genome was evolved on")
+	window := flags.Int("window", 1024, "the number of leading bytes of the corpus the genome was evolved on")
+	input := flags.String("input", "", "the file to tokenize")
+	flags.Parse(args)
+
+	g, err := LoadGenome(*genome)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	file, err := os.Open(*corpus)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	training, err := LoadInput(file, *window)
+	file.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := g.Validate(len(training)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	data, err := ioutil.ReadFile(*input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	t := tokenizer.NewTokenizer(g.Segments(training))
+	for _, id := range t.Encode(data) {
+		fmt.Println(id)
 	}
 }
 
 func main() {
-	rand.Seed(1)
+	if len(os.Args) > 1 && os.Args[1] == "complexity" {
+		if err := ComplexityCommand(os.Args[2:], os.Stdout); err != nil && err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "tokenize" {
+		TokenizeCommand(os.Args[2:])
+		return
+	}
+	flag.Parse()
+
+	cfg := DefaultGAConfig()
+	switch *FlagObjective {
+	case "complexity":
+		cfg.Fitness.Objective = ObjectiveComplexity
+	case "mdl":
+		cfg.Fitness.Objective = ObjectiveMDL
+	default:
+		fmt.Fprintf(os.Stderr, "unknown objective %s\n", *FlagObjective)
+		os.Exit(1)
+	}
+
+	switch *FlagFormat {
+	case "text", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %s\n", *FlagFormat)
+		os.Exit(1)
+	}
+
+	switch *FlagBaseline {
+	case "", "gzip":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown baseline %s\n", *FlagBaseline)
+		os.Exit(1)
+	}
+
+	switch *FlagCrossover {
+	case "point1", "point2", "uniform":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown crossover %s\n", *FlagCrossover)
+		os.Exit(1)
+	}
+
+	operators, err := ParseOperators(*FlagOperators)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	switch *FlagSelection {
+	case "top10", "tournament":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown selection %s\n", *FlagSelection)
+		os.Exit(1)
+	}
 
-	input, err := ioutil.ReadFile("curie.wiki")
+	path := *FlagInput
+	if path == "" {
+		path = "curie.wiki"
+		if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+			path = "-"
+		}
+	}
+	var reader io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		reader = file
+	}
+	if *FlagTile && *FlagMaxGenerations <= 0 && *FlagPatience <= 0 {
+		fmt.Fprintln(os.Stderr, "-tile needs -max-generations or -patience")
+		os.Exit(1)
+	}
+	if *FlagTile && *FlagCheckpoint != "" {
+		fmt.Fprintln(os.Stderr, "-tile can't be used with -checkpoint")
+		os.Exit(1)
+	}
+	if *FlagTile && *FlagValidationLength > 0 {
+		fmt.Fprintln(os.Stderr, "-tile can't be used with -validation-length")
+		os.Exit(1)
+	}
+	window := *FlagWindow
+	if *FlagTile {
+		window = 0
+	} else if end := *FlagValidationOffset + *FlagValidationLength; *FlagValidationLength > 0 && window > 0 && end > window {
+		// the validation window is read past the training window
+		window = end
+	}
+	input, err := LoadInput(reader, window)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(input) == 0 {
+		fmt.Fprintln(os.Stderr, "the input is empty")
+		os.Exit(1)
 	}
-	Curie = input[:1024]
+	tiles := [][]byte{input}
+	if *FlagTile {
+		tiles = Tiles(input, *FlagWindow)
+	}
+	training := tiles[0]
+	if *FlagWindow > 0 && len(training) > *FlagWindow {
+		training = training[:*FlagWindow]
+	}
+	SetCorpus(training)
 
-	genomes := make([]Genome, 0, Size)
-	for i := 0; i < Size; i++ {
-		genome := NewGenome()
-		genomes = append(genomes, genome)
+	if *FlagStats {
+		complexity := cdf16.NewComplexity(cfg.Fitness.Depth)
+		complexity.Train(training)
+		data, err := json.Marshal(complexity.Stats())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
 	}
 
-	fini, exit := false, make(chan os.Signal)
+	seed := *FlagSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if *FlagPopulation <= 0 {
+		fmt.Fprintln(os.Stderr, "the population must be positive")
+		os.Exit(1)
+	}
+	cfg.Window = *FlagWindow
+	cfg.Population = *FlagPopulation
+	cfg.Elite = *FlagElite
+	cfg.Selection = *FlagSelection
+	cfg.SelectionPool = *FlagSelectionPool
+	cfg.TournamentSize = *FlagTournamentSize
+	cfg.Crossover = *FlagCrossover
+	cfg.Operators = operators
+	cfg.UniformRate = *FlagUniformRate
+	cfg.CanonicalInit = *FlagCanonicalInit
+	cfg.MutationStart = *FlagMutationStart
+	cfg.MutationEnd = *FlagMutationEnd
+	cfg.MutationDecay = *FlagMutationDecay
+	cfg.Mutation = Mutation{
+		Split:      *FlagSplit,
+		Merge:      *FlagMerge,
+		BlockReset: *FlagBlockReset,
+	}
+	cfg.Fitness.VocabPenalty = *FlagVocabPenalty
+	cfg.Fitness.ContiguityWeight = *FlagContiguity
+	cfg.Fitness.Varint = *FlagVarint
+	cfg.MaxGenerations = *FlagMaxGenerations
+	cfg.Patience = *FlagPatience
+	cfg.Epsilon = *FlagEpsilon
+	cfg.Deltas = *FlagDeltas
+	cfg.Adaptive = *FlagAdaptive
+	cfg.AdaptiveRate = *FlagAdaptiveRate
+	cfg.ShareRadius = *FlagShareRadius
+	cfg.ShareStrength = *FlagShareStrength
+	cfg.Strict = *FlagStrict
+	cfg.Workers = *FlagWorkers
+	cfg.MemLimit = *FlagMemLimit
+	cfg.Dedup = *FlagDedup
+	cfg.Seed = seed
+	cfg.ValidationWindow = Span{Offset: *FlagValidationOffset, Length: *FlagValidationLength}
+	cfg.ValidationEvery = *FlagValidationEvery
+	if *FlagResume != "" {
+		genome, err := LoadGenome(*FlagResume)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := genome.Validate(len(training)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		cfg.Genomes = append(cfg.Genomes, genome)
+	}
+	if *FlagSeedGenomes != "" {
+		seeds, err := LoadGenomes(*FlagSeedGenomes)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for i := range seeds {
+			if err := seeds[i].Validate(len(training)); err != nil {
+				fmt.Fprintf(os.Stderr, "seed genome %d: %v\n", i, err)
+				os.Exit(1)
+			}
+		}
+		cfg.Genomes = append(cfg.Genomes, seeds...)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exit := make(chan os.Signal, 1)
 	signal.Notify(exit, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-exit
 		fmt.Println("exit")
-		fini = true
+		cancel()
 	}()
 
-	for {
-		done := make(chan int, 8)
-		fitness := func(i int) {
-			genomes[i].ComputeFitness()
-			done <- i
+	var metrics *csv.Writer
+	if *FlagLog != "" {
+		file, err := os.Create(*FlagLog)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		for i := range genomes {
-			go fitness(i)
-		}
-		for range genomes {
-			<-done
+		defer file.Close()
+		metrics = csv.NewWriter(file)
+		metrics.Write(MetricsHeader)
+		metrics.Flush()
+	}
+
+	RunTilesFunc(tiles, cfg, func(tile int, ga *GA) bool {
+		if tile > 0 {
+			SetCorpus(ga.Corpus)
 		}
-		sort.Slice(genomes, func(i, j int) bool {
-			return genomes[i].Fitness < genomes[j].Fitness
-		})
-		genomes = genomes[:Size]
-		tokens := make(map[int64]bool)
-		for _, t := range genomes[0].Tokens {
-			tokens[t] = true
+		out, topKDir := *FlagOut, *FlagTopKDir
+		if *FlagTile {
+			out, topKDir = TilePath(out, tile), TilePath(topKDir, tile)
+			if !*FlagQuiet {
+				fmt.Printf("tile %d of %d\n", tile+1, len(tiles))
+			}
 		}
-		fmt.Println(genomes[0].Fitness, len(tokens))
 
-		if fini {
-			genomes[0].Print()
-			break
+		if *FlagCheckpoint != "" {
+			if _, err := os.Stat(*FlagCheckpoint); err == nil {
+				checkpoint, err := LoadCheckpoint(*FlagCheckpoint)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+				for i := range checkpoint.Genomes {
+					if err := checkpoint.Genomes[i].Validate(len(ga.Corpus)); err != nil {
+						fmt.Fprintf(os.Stderr, "checkpoint genome %d: %v\n", i, err)
+						os.Exit(1)
+					}
+				}
+				ga.Restore(checkpoint)
+			}
 		}
+		for {
+			stats, complete := ga.Evaluate(ctx)
+			genomes := ga.Genomes
+			ratio := 0.0
+			if complete && (!*FlagQuiet || metrics != nil) {
+				ratio = genomes[0].Report(ga.Corpus, ga.Config.Fitness).CodedRatio
+			}
+			if complete && !*FlagQuiet {
+				fmt.Println(stats.Generation, stats.Elapsed.Milliseconds(), stats.Best, stats.Distinct, stats.Diversity, ratio)
+			}
+			if complete && stats.Validated && !*FlagQuiet {
+				fmt.Println("validation", stats.Generation, stats.Validation)
+			}
+			if complete && metrics != nil {
+				if err := WriteMetrics(metrics, stats, ratio); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}
 
-		for i := 0; i < Size; i++ {
-			switch rand.Intn(3) {
-			case 0:
-				a := rand.Intn(10)
-				cp := genomes[a].Copy()
-				mutate := rand.Intn(len(cp.Tokens))
-				switch rand.Intn(2) {
-				case 0:
-					cp.Tokens[mutate]++
-					if length := int64(len(Curie) - 1); cp.Tokens[mutate] > length {
-						cp.Tokens[mutate] = length
+			if !complete || ctx.Err() != nil || ga.Done() {
+				switch {
+				case *FlagFormat == "json" && out == "":
+					data, err := json.Marshal(&genomes[0])
+					if err != nil {
+						fmt.Fprintln(os.Stderr, err)
 					}
-				case 1:
-					cp.Tokens[mutate]--
-					if cp.Tokens[mutate] < 0 {
-						cp.Tokens[mutate] = 0
+					fmt.Println(string(data))
+				case *FlagFormat != "json":
+					genomes[0].Print()
+				}
+				if *FlagBaseline == "gzip" {
+					ratio, err := GzipRatio(ga.Corpus)
+					if err != nil {
+						fmt.Fprintln(os.Stderr, err)
+					}
+					fmt.Printf("gzip ratio=%f best ratio=%f\n", ratio, genomes[0].Report(ga.Corpus, ga.Config.Fitness).CodedRatio)
+				}
+				// the history is empty if the run stopped during the first generation
+				if history := ga.History; len(history) > 0 {
+					min, max := history[0], history[0]
+					for _, count := range history {
+						if count < min {
+							min = count
+						}
+						if count > max {
+							max = count
+						}
+					}
+					fmt.Printf("distinct tokens min=%d max=%d final=%d %s\n",
+						min, max, history[len(history)-1], Sparkline(history, 64))
+				}
+				if *FlagHistory {
+					fmt.Println(ga.History)
+				}
+				if *FlagDeltas {
+					ga.Deltas.Print()
+				}
+				if *FlagAdaptive {
+					fmt.Println("operator probabilities", ga.Adaptive.Probabilities[OperatorMutate:])
+				}
+				if *FlagCompareBaseline {
+					baseline := BaselineGenome(ga.Corpus)
+					baseline.ComputeFitness(ga.Corpus, ga.Config.Fitness)
+					best, base := genomes[0].Report(ga.Corpus, ga.Config.Fitness), baseline.Report(ga.Corpus, ga.Config.Fitness)
+					fmt.Printf("baseline fitness=%f ratio=%f best fitness=%f ratio=%f improvement=%f\n",
+						baseline.Fitness, base.CompressionRatio, genomes[0].Fitness, best.CompressionRatio,
+						baseline.Fitness-genomes[0].Fitness)
+				}
+				if out != "" {
+					var err error
+					if *FlagFormat == "json" {
+						var data []byte
+						data, err = json.Marshal(&genomes[0])
+						if err == nil {
+							err = ioutil.WriteFile(out, data, 0644)
+						}
+					} else {
+						err = genomes[0].Save(out)
+					}
+					if err != nil {
+						fmt.Fprintln(os.Stderr, err)
+					}
+				}
+				if *FlagTopK > 0 {
+					err := SaveTopK(genomes, *FlagTopK, topKDir)
+					if err != nil {
+						fmt.Fprintln(os.Stderr, err)
 					}
 				}
-				genomes = append(genomes, cp)
-			case 1:
-				a, b := rand.Intn(10), rand.Intn(10)
-				cpa, cpb := genomes[a].Copy(), genomes[b].Copy()
-				x, y := rand.Intn(len(cpa.Tokens)), rand.Intn(len(cpb.Tokens))
-				cpa.Tokens[x], cpb.Tokens[y] = cpb.Tokens[y], cpa.Tokens[x]
-				genomes = append(genomes, cpa, cpb)
-			case 2:
-				a, b := rand.Intn(10), rand.Intn(10)
-				cpa, cpb := genomes[a].Copy(), genomes[b].Copy()
-				x, y := rand.Intn(len(cpa.Tokens)), rand.Intn(len(cpb.Tokens))
-				cpa.Tokens[x] = cpb.Tokens[y]
-				genomes = append(genomes, cpa, cpb)
+				break
+			}
+
+			save := *FlagCheckpoint != "" && *FlagCheckpointEvery > 0 && (ga.Generation+1)%*FlagCheckpointEvery == 0
+			if save && *FlagTopK > 0 {
+				// the population is sorted by fitness until it is bred
+				err := SaveTopK(genomes, *FlagTopK, topKDir)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}
+			ga.Breed()
+			if save {
+				checkpoint := ga.Checkpoint()
+				err := checkpoint.Save(*FlagCheckpoint)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
 			}
 		}
-	}
+		return ctx.Err() == nil
+	})
 }
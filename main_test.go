@@ -0,0 +1,694 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pointlander/token/cdf16"
+)
+
+// testCorpus is an in-memory corpus for short runs
+var testCorpus = []byte("Maria Sklodowska was born in Warsaw. Her father taught mathematics and physics, " +
+	"and her mother ran a boarding school for girls in Warsaw.")
+
+// curie returns the first 1024 bytes of curie.wiki
+func curie(t testing.TB) []byte {
+	data, err := ioutil.ReadFile("curie.wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data[:1024]
+}
+
+// tempDir creates a temporary directory, the caller removes it
+func tempDir(t testing.TB) string {
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// words segments a corpus after each space
+func words(t testing.TB, corpus []byte) Genome {
+	boundaries := []int{}
+	for i, b := range corpus[:len(corpus)-1] {
+		if b == ' ' {
+			boundaries = append(boundaries, i+1)
+		}
+	}
+	g, err := GenomeFromSegmentation(boundaries, len(corpus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// testConfig returns the parameters of a short deterministic run on testCorpus
+func testConfig() GAConfig {
+	cfg := DefaultGAConfig()
+	cfg.Population, cfg.MaxGenerations, cfg.Workers, cfg.Seed = 10, 3, 2, 1
+	return cfg
+}
+
+func TestVarintStream(t *testing.T) {
+	boundaries := make([]int, 0, len(testCorpus)/4)
+	for i := 0; i < len(testCorpus); i += 4 {
+		boundaries = append(boundaries, i)
+	}
+	g, err := GenomeFromSegmentation(boundaries, len(testCorpus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed, varint := g.Stream(false), g.Stream(true)
+	if len(fixed) != 8*len(testCorpus) || len(varint) != len(testCorpus) {
+		t.Fatalf("the streams are %d and %d bytes for %d small ids", len(fixed), len(varint), len(testCorpus))
+	}
+	a := cdf16.NewComplexity(cdf16.CDF16Depth).Complexity(fixed)
+	b := cdf16.NewComplexity(cdf16.CDF16Depth).Complexity(varint)
+	if a >= b {
+		t.Fatalf("the fixed width stream is %f bits per byte, not below the %f of the varint stream", a, b)
+	}
+}
+
+func TestCanonicalInit(t *testing.T) {
+	cfg := testConfig()
+	cfg.CanonicalInit = true
+	ga := NewGA(testCorpus, cfg)
+	for i, g := range ga.Genomes {
+		next := int64(0)
+		for j, token := range g.Tokens {
+			if j > 0 && token == g.Tokens[j-1] {
+				continue
+			}
+			if token != next {
+				t.Fatalf("genome %d has token %d at %d, the next canonical id is %d", i, token, j, next)
+			}
+			next++
+		}
+	}
+}
+
+func TestComputeFitnessReuse(t *testing.T) {
+	cfg, rnd := DefaultFitnessConfig(), rand.New(rand.NewSource(1))
+	model := cdf16.NewComplexity(cfg.Depth)
+	for i := 0; i < 8; i++ {
+		g := NewGenome(rnd, len(testCorpus), false)
+		fresh, count := 0.0, 0
+		tokens := g.Groups(testCorpus)
+		for _, key := range Keys(tokens) {
+			fresh += float64(cdf16.NewComplexity(cfg.Depth).Complexity(tokens[key]))
+			count++
+		}
+		fresh = fresh/float64(count) + float64(cdf16.NewComplexity(cfg.Depth).Complexity(g.Stream(false)))
+		g.computeFitness(context.Background(), testCorpus, cfg, model)
+		if g.Fitness != fresh {
+			t.Fatalf("genome %d has fitness %f with a reused model and %f with new models", i, g.Fitness, fresh)
+		}
+	}
+}
+
+func BenchmarkComputeFitness(b *testing.B) {
+	input, cfg := curie(b), DefaultFitnessConfig()
+	g := NewGenome(rand.New(rand.NewSource(1)), len(input), false)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.ComputeFitness(input, cfg)
+	}
+}
+
+func BenchmarkComputeFitnessReuse(b *testing.B) {
+	input, cfg := curie(b), DefaultFitnessConfig()
+	g := NewGenome(rand.New(rand.NewSource(1)), len(input), false)
+	model := cdf16.NewComplexity(cfg.Depth)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.computeFitness(context.Background(), input, cfg, model)
+	}
+}
+
+func TestSaveTopK(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	SetCorpus(testCorpus)
+	ga := NewGA(testCorpus, testConfig())
+	ga.Evaluate(context.Background())
+	if err := SaveTopK(ga.Genomes, 3, dir); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("%d files are written, not 3", len(files))
+	}
+	fitness := math.Inf(-1)
+	for i := 0; i < 3; i++ {
+		g, err := LoadGenome(filepath.Join(dir, fmt.Sprintf("%d.json", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.Fitness < fitness {
+			t.Fatalf("genome %d has fitness %f, it is better than the %f before it", i, g.Fitness, fitness)
+		}
+		fitness = g.Fitness
+	}
+}
+
+func TestMutate(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	g := Genome{Tokens: make([]int64, 64)}
+	for i := range g.Tokens {
+		g.Tokens[i] = 5
+	}
+	for i := 0; i < 1000; i++ {
+		cp, changed := g.Mutate(rnd, 10), 0
+		for j, token := range cp.Tokens {
+			if token != g.Tokens[j] {
+				changed++
+				if token != 4 && token != 6 {
+					t.Fatalf("token %d is mutated to %d", j, token)
+				}
+			}
+		}
+		if changed != 1 {
+			t.Fatalf("%d tokens are mutated", changed)
+		}
+	}
+
+	// the mutations are clamped to [0, maxToken]
+	edge := Genome{Tokens: []int64{0, 1, 0, 1}}
+	for i := 0; i < 1000; i++ {
+		for _, token := range edge.Mutate(rnd, 1).Tokens {
+			if token < 0 || token > 1 {
+				t.Fatalf("a token is mutated to %d", token)
+			}
+		}
+	}
+}
+
+func TestComplexityCommand(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	empty := filepath.Join(dir, "empty")
+	if err := ioutil.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ranges := map[string][2]float64{
+		"curie.wiki": {2, 5},
+		empty:        {0, 0},
+	}
+	for path, expected := range ranges {
+		var output bytes.Buffer
+		if err := ComplexityCommand([]string{"-input", path}, &output); err != nil {
+			t.Fatal(err)
+		}
+		bits, err := strconv.ParseFloat(strings.TrimSpace(output.String()), 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bits < expected[0] || bits > expected[1] {
+			t.Fatalf("%s is %f bits per byte, not in [%f, %f]", path, bits, expected[0], expected[1])
+		}
+	}
+	if err := ComplexityCommand([]string{"-input", filepath.Join(dir, "missing")}, ioutil.Discard); err == nil {
+		t.Fatal("a missing file isn't an error")
+	}
+}
+
+func TestComputeFitnessExpired(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	corpus := curie(t)
+	g := NewGenome(rand.New(rand.NewSource(1)), len(corpus), false)
+	if approximate := g.ComputeFitnessCtx(ctx, corpus, DefaultFitnessConfig()); !approximate {
+		t.Fatal("the fitness under an expired context isn't approximate")
+	}
+	// no token is scored once the deadline has passed
+	if g.Fitness != 0 {
+		t.Fatalf("the fitness under an expired context is %f", g.Fitness)
+	}
+	if approximate := g.ComputeFitnessCtx(context.Background(), corpus, DefaultFitnessConfig()); approximate {
+		t.Fatal("the fitness without a deadline is approximate")
+	}
+}
+
+func TestGenomeFromSegmentation(t *testing.T) {
+	boundaries := []int{6, 17, 25, 40}
+	g, err := GenomeFromSegmentation(boundaries, len(testCorpus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, begin := g.Groups(testCorpus), 0
+	if len(groups) != len(boundaries)+1 {
+		t.Fatalf("%d groups for %d segments", len(groups), len(boundaries)+1)
+	}
+	for i, end := range append(boundaries, len(testCorpus)) {
+		if group := groups[int64(i)]; string(group) != string(testCorpus[begin:end]) {
+			t.Fatalf("group %d is %q, not %q", i, group, testCorpus[begin:end])
+		}
+		begin = end
+	}
+
+	for _, invalid := range [][]int{{17, 6}, {6, 6}, {-1}, {len(testCorpus)}} {
+		if _, err := GenomeFromSegmentation(invalid, len(testCorpus)); err == nil {
+			t.Fatalf("the boundaries %v are accepted", invalid)
+		}
+	}
+}
+
+func TestContiguityWeight(t *testing.T) {
+	scattered := Genome{Tokens: make([]int64, len(testCorpus))}
+	for i := range scattered.Tokens {
+		// token 0 is scattered over every other block of 8 bytes
+		scattered.Tokens[i] = int64(i / 8 % 2 * (i / 8))
+	}
+	contiguous := words(t, testCorpus)
+	if scatter := contiguous.Scatter(); scatter != 0 {
+		t.Fatalf("the contiguous genome has a scatter of %f", scatter)
+	}
+	if scatter := scattered.Scatter(); scatter <= 0 {
+		t.Fatalf("the scattered genome has a scatter of %f", scatter)
+	}
+
+	cfg := DefaultFitnessConfig()
+	if cfg.ContiguityWeight != 0 {
+		t.Fatalf("the default contiguity weight is %f", cfg.ContiguityWeight)
+	}
+	for _, g := range []Genome{scattered, contiguous} {
+		g.ComputeFitness(testCorpus, cfg)
+		unweighted := g.Fitness
+		cfg.ContiguityWeight = 2
+		g.ComputeFitness(testCorpus, cfg)
+		cfg.ContiguityWeight = 0
+		if penalty, expected := g.Fitness-unweighted, 2*g.Scatter(); math.Abs(penalty-expected) > 1e-9 {
+			t.Fatalf("the contiguity penalty is %f, not %f", penalty, expected)
+		}
+	}
+}
+
+func TestBaselineGenome(t *testing.T) {
+	corpus, cfg := []byte("abab"), DefaultFitnessConfig()
+	baseline := BaselineGenome(corpus)
+	for i, token := range baseline.Tokens {
+		if token != int64(corpus[i]) {
+			t.Fatalf("the token at %d is %d, not %d", i, token, corpus[i])
+		}
+	}
+	groups := baseline.Groups(corpus)
+	if len(groups) != 2 || string(groups['a']) != "aa" || string(groups['b']) != "bb" {
+		t.Fatalf("the groups of the baseline are %q", groups)
+	}
+	complexity := func(input []byte) float64 {
+		return float64(cdf16.NewComplexity(cfg.Depth).Complexity(input))
+	}
+	expected := (complexity([]byte("aa"))+complexity([]byte("bb")))/2 + complexity(baseline.Stream(cfg.Varint))
+	if baseline.ComputeFitness(corpus, cfg); baseline.Fitness != expected {
+		t.Fatalf("the fitness of the baseline is %f, not %f", baseline.Fitness, expected)
+	}
+}
+
+func TestSaveLoadGenome(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	g := NewGenome(rand.New(rand.NewSource(1)), 4096, false)
+	g.Fitness = 7.5
+	path := filepath.Join(dir, "genome.gob")
+	if err := g.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGenome(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Fitness != g.Fitness {
+		t.Fatalf("the loaded fitness is %f, not %f", loaded.Fitness, g.Fitness)
+	}
+	if len(loaded.Tokens) != len(g.Tokens) {
+		t.Fatalf("%d tokens are loaded, not %d", len(loaded.Tokens), len(g.Tokens))
+	}
+	for i, token := range g.Tokens {
+		if loaded.Tokens[i] != token {
+			t.Fatalf("the loaded token at %d is %d, not %d", i, loaded.Tokens[i], token)
+		}
+	}
+	if _, err := LoadGenome(filepath.Join(dir, "missing.gob")); err == nil {
+		t.Fatal("a missing genome is loaded")
+	}
+}
+
+func TestMutationCount(t *testing.T) {
+	const length = 1 << 16
+	cfg := DefaultGAConfig()
+	for _, decay := range []float64{10, cfg.MutationDecay} {
+		previous := MutationCount(0, length, cfg.MutationStart, cfg.MutationEnd, decay)
+		if expected := int(math.Round(cfg.MutationStart * length)); previous != expected {
+			t.Fatalf("%d tokens are mutated in the first generation, not %d", previous, expected)
+		}
+		for generation := 1; generation < 100; generation++ {
+			count := MutationCount(generation, length, cfg.MutationStart, cfg.MutationEnd, decay)
+			if count > previous {
+				t.Fatalf("%d tokens are mutated in generation %d, up from %d", count, generation, previous)
+			}
+			previous = count
+		}
+		if decay == 10 && previous != 1 {
+			t.Fatalf("%d tokens are mutated after 10 time constants", previous)
+		}
+	}
+	// a short genome mutates a single token
+	if count := MutationCount(0, 10, cfg.MutationStart, cfg.MutationEnd, cfg.MutationDecay); count != 1 {
+		t.Fatalf("%d tokens of 10 are mutated", count)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	corpus := []byte("the cat sat")
+	// the token 1 is used by two runs, which are separate segments
+	g := Genome{Tokens: []int64{1, 1, 1, 1, 2, 2, 2, 2, 1, 1, 1}}
+	if boundaries := g.Boundaries(); !reflect.DeepEqual(boundaries, []int{4, 8}) {
+		t.Fatalf("the boundaries are %v", boundaries)
+	}
+	segments := []string{}
+	for _, segment := range g.Segments(corpus) {
+		segments = append(segments, string(segment))
+	}
+	if !reflect.DeepEqual(segments, []string{"the ", "cat ", "sat"}) {
+		t.Fatalf("the segments are %q", segments)
+	}
+
+	single := Genome{Tokens: []int64{3, 3, 3}}
+	if boundaries, segments := single.Boundaries(), single.Segments(corpus); len(boundaries) != 0 ||
+		len(segments) != 1 || string(segments[0]) != "the" {
+		t.Fatalf("a single token has the boundaries %v and the segments %q", boundaries, segments)
+	}
+	empty := Genome{}
+	if boundaries, segments := empty.Boundaries(), empty.Segments(corpus); len(boundaries) != 0 || segments != nil {
+		t.Fatalf("an empty genome has the boundaries %v and the segments %q", boundaries, segments)
+	}
+}
+
+func TestGenomeJSON(t *testing.T) {
+	binary := []byte{'a', 'b', 0xff, 0xfe, 'c'}
+	for _, corpus := range [][]byte{testCorpus, binary} {
+		SetCorpus(corpus)
+		g := words(t, corpus)
+		g.Fitness = 6.5
+		data, err := json.Marshal(&g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var output struct {
+			Encoding string
+			Segments []string
+		}
+		if err := json.Unmarshal(data, &output); err != nil {
+			t.Fatal(err)
+		}
+		segments := g.Segments(corpus)
+		if len(output.Segments) != len(segments) {
+			t.Fatalf("there are %d segments, not %d", len(output.Segments), len(segments))
+		}
+		for i, segment := range segments {
+			expected := string(segment)
+			if output.Encoding == "base64" {
+				expected = base64.StdEncoding.EncodeToString(segment)
+			}
+			if output.Segments[i] != expected {
+				t.Fatalf("segment %d is %q, not %q in %s", i, output.Segments[i], expected, output.Encoding)
+			}
+		}
+		if valid := utf8.Valid(corpus); valid != (output.Encoding == "utf8") {
+			t.Fatalf("the segments of a corpus that is valid UTF-8 %t are encoded as %s", valid, output.Encoding)
+		}
+
+		var decoded Genome
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Fitness != g.Fitness || !reflect.DeepEqual(decoded.Tokens, g.Tokens) {
+			t.Fatalf("the genome decodes as %v %v, not %v %v", decoded.Fitness, decoded.Tokens, g.Fitness, g.Tokens)
+		}
+	}
+}
+
+func TestLoadInput(t *testing.T) {
+	for _, window := range []int{0, 1, 10, len(testCorpus), 2 * len(testCorpus)} {
+		input, err := LoadInput(bytes.NewReader(testCorpus), window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := testCorpus
+		if window > 0 && window < len(testCorpus) {
+			expected = testCorpus[:window]
+		}
+		if !bytes.Equal(input, expected) {
+			t.Fatalf("the input of window %d is %q, not %q", window, input, expected)
+		}
+	}
+	// only the window is read from the reader
+	reader := bytes.NewReader(testCorpus)
+	if _, err := LoadInput(reader, 10); err != nil {
+		t.Fatal(err)
+	}
+	if reader.Len() != len(testCorpus)-10 {
+		t.Fatalf("%d bytes are read for a window of 10", len(testCorpus)-reader.Len())
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.csv")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := csv.NewWriter(file)
+	metrics.Write(MetricsHeader)
+	cfg := testConfig()
+	cfg.MaxGenerations = 5
+	ga := NewGA(testCorpus, cfg)
+	for {
+		stats, _ := ga.Evaluate(context.Background())
+		if err := WriteMetrics(metrics, stats, .5); err != nil {
+			t.Fatal(err)
+		}
+		if ga.Done() {
+			break
+		}
+		ga.Breed()
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	rows, err := csv.NewReader(in).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != cfg.MaxGenerations+1 || !reflect.DeepEqual(rows[0], MetricsHeader) {
+		t.Fatalf("the metrics have %d rows and the header %v", len(rows), rows[0])
+	}
+	for i, row := range rows[1:] {
+		if len(row) != len(MetricsHeader) {
+			t.Fatalf("row %d has %d columns, not %d", i, len(row), len(MetricsHeader))
+		}
+		if generation, err := strconv.Atoi(row[0]); err != nil || generation != i {
+			t.Fatalf("row %d is generation %s", i, row[0])
+		}
+		for _, column := range row[1:] {
+			if _, err := strconv.ParseFloat(column, 64); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestSplitMergeRun(t *testing.T) {
+	distinct := func(g Genome) int {
+		tokens := make(map[int64]bool)
+		for _, token := range g.Tokens {
+			tokens[token] = true
+		}
+		return len(tokens)
+	}
+	g := Genome{Tokens: []int64{0, 0, 0, 0, 1, 1, 2}}
+	g.SplitRun(1, 6)
+	if !reflect.DeepEqual(g.Tokens, []int64{0, 0, 3, 3, 1, 1, 2}) || distinct(g) != 4 {
+		t.Fatalf("the split genome is %v", g.Tokens)
+	}
+	// a run of one token and a genome without an unused id aren't split
+	g.SplitRun(6, 6)
+	full := Genome{Tokens: []int64{0, 0, 1}}
+	full.SplitRun(0, 1)
+	if distinct(g) != 4 || distinct(full) != 2 {
+		t.Fatalf("the genomes are split to %v and %v", g.Tokens, full.Tokens)
+	}
+
+	g.MergeRun(2)
+	if !reflect.DeepEqual(g.Tokens, []int64{0, 0, 3, 3, 3, 3, 2}) || distinct(g) != 3 {
+		t.Fatalf("the merged genome is %v", g.Tokens)
+	}
+	// the last run has nothing to merge with
+	g.MergeRun(6)
+	if distinct(g) != 3 {
+		t.Fatalf("the last run is merged to %v", g.Tokens)
+	}
+	// a token that is used by another run isn't removed by a merge
+	shared := Genome{Tokens: []int64{0, 1, 0, 1}}
+	shared.MergeRun(0)
+	if !reflect.DeepEqual(shared.Tokens, []int64{0, 0, 0, 1}) || distinct(shared) != 2 {
+		t.Fatalf("the shared genome is merged to %v", shared.Tokens)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	corrupt := map[string]Genome{
+		"the genome has 3 tokens but the corpus has 4 bytes": {Tokens: []int64{0, 1, 2}},
+		"token -1 at 2 is out of range [0, 4)":               {Tokens: []int64{0, 1, -1, 3}},
+		"token 4 at 3 is out of range [0, 4)":                {Tokens: []int64{0, 1, 2, 4}},
+	}
+	for expected, g := range corrupt {
+		if err := g.Validate(4); err == nil || err.Error() != expected {
+			t.Fatalf("the corrupt genome %v has the error %v, not %s", g.Tokens, err, expected)
+		}
+	}
+	valid := Genome{Tokens: []int64{0, 3, 3, 1}}
+	if err := valid.Validate(4); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"garbage.gob": "not a genome",
+		"range.json":  `{"fitness": 1, "tokens": [0, 1, 9, 3]}`,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := LoadGenome(filepath.Join(dir, "garbage.gob")); err == nil {
+		t.Fatal("a corrupt file is loaded")
+	}
+	g, err := LoadGenome(filepath.Join(dir, "range.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Validate(4); err == nil || !strings.Contains(err.Error(), "token 9 at 2") {
+		t.Fatalf("the loaded genome with an id out of range has the error %v", err)
+	}
+}
+
+func TestGzipRatio(t *testing.T) {
+	ratio, err := GzipRatio(curie(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// text compresses, though a short input pays for the gzip header
+	if ratio <= .3 || ratio >= .9 {
+		t.Fatalf("the gzip ratio of the text is %f", ratio)
+	}
+	if ratio, err := GzipRatio(nil); err != nil || ratio != 1 {
+		t.Fatalf("the gzip ratio of an empty input is %f %v", ratio, err)
+	}
+}
+
+func TestDegenerateGenomes(t *testing.T) {
+	corpus, cfg := curie(t), DefaultFitnessConfig()
+	baseline, grouped := BaselineGenome(corpus), words(t, corpus)
+	baseline.ComputeFitness(corpus, cfg)
+	grouped.ComputeFitness(corpus, cfg)
+
+	// a single token is raised to the fitness of not tokenizing
+	single := Genome{Tokens: make([]int64, len(corpus))}
+	single.ComputeFitness(corpus, cfg)
+	if single.Fitness != baseline.Fitness {
+		t.Fatalf("the single token genome has fitness %f, not the baseline %f", single.Fitness, baseline.Fitness)
+	}
+	// a token for every position doesn't win either
+	distinct := Genome{Tokens: make([]int64, len(corpus))}
+	for i := range distinct.Tokens {
+		distinct.Tokens[i] = int64(i)
+	}
+	distinct.ComputeFitness(corpus, cfg)
+	if distinct.Fitness <= baseline.Fitness || distinct.Fitness <= grouped.Fitness {
+		t.Fatalf("the genome of distinct tokens has fitness %f, the baseline %f and the words %f",
+			distinct.Fitness, baseline.Fitness, grouped.Fitness)
+	}
+
+	// the baseline of a corpus of one repeated byte is a single token too, so it isn't raised
+	repeated := bytes.Repeat([]byte{'a'}, 64)
+	single = Genome{Tokens: make([]int64, len(repeated))}
+	single.ComputeFitness(repeated, cfg)
+	unclamped, _ := single.complexity(context.Background(), single.Groups(repeated), cfg, cdf16.NewComplexity(cfg.Depth))
+	if single.Fitness != unclamped {
+		t.Fatalf("the single token of a repeated byte has fitness %f, not %f", single.Fitness, unclamped)
+	}
+}
+
+func TestBlockReset(t *testing.T) {
+	const start, maxToken = 100, 1 << 40
+	rnd, g := rand.New(rand.NewSource(1)), Genome{Tokens: make([]int64, 200)}
+	for i := range g.Tokens {
+		g.Tokens[i] = int64(i)
+	}
+	total, runs := 0, 0
+	for i := 0; i < 1000; i++ {
+		cp := g.Copy()
+		cp.BlockReset(rnd, start, maxToken)
+		// the ids are drawn from so many that the block differs from the original at every position
+		end := start
+		for end < len(cp.Tokens) && cp.Tokens[end] != g.Tokens[end] {
+			end++
+		}
+		if end == start || end-start > BlockResetMaximum {
+			t.Fatalf("a block of %d tokens is reset", end-start)
+		}
+		for j, token := range cp.Tokens {
+			if (j < start || j >= end) && token != g.Tokens[j] {
+				t.Fatalf("the token at %d outside of the block [%d, %d) is reset", j, start, end)
+			}
+		}
+		total += end - start
+		for j := start; j < end; j++ {
+			if j == start || cp.Tokens[j] != cp.Tokens[j-1] {
+				runs++
+			}
+		}
+	}
+	// a new run starts with a probability of 1 in 8 after the first token of the block
+	expected := 1000 + float64(total-1000)/8
+	if math.Abs(float64(runs)-expected) > expected/10 {
+		t.Fatalf("the blocks have %d runs, not about %f", runs, expected)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+
+	"github.com/pointlander/token/cdf16"
+)
+
+// Objective is a fitness objective
+type Objective int
+
+const (
+	// ObjectiveComplexity is the mean token complexity plus the stream complexity
+	ObjectiveComplexity Objective = iota
+	// ObjectiveMDL is the two part description length computed by MDLFull
+	ObjectiveMDL
+)
+
+// MDLFull computes a two part minimum description length of the corpus in bits per byte: the
+// bits needed to encode each token's bytes and the token id stream under their models, plus the
+// bits needed to describe the models. The models are adaptive so their probabilities cost
+// nothing to transmit, instead a model is charged one symbol for every context node beyond its root
+// and one symbol per distinct byte it has seen, and the dictionary is charged
+// log2(len(corpus)) bits per token id. Many small token groups each pay for their own model, so
+// fragmented groupings cost more than under the plain complexity objective. The context models are
+// cfg.Depth deep and the stream is encoded as cfg.Varint says.
+func (g *Genome) MDLFull(corpus []byte, cfg FitnessConfig) float64 {
+	if len(corpus) == 0 {
+		return 0
+	}
+	symbolBits := math.Log2(cdf16.CDF16Size)
+	encode := func(input []byte) (float64, float64) {
+		complexity := cdf16.NewComplexity(cfg.Depth)
+		bitsPerByte, _ := complexity.ComplexityFull(input)
+		seen := make(map[byte]bool)
+		for _, s := range input {
+			seen[s] = true
+		}
+		stats := complexity.Stats()
+		model := float64(stats.Nodes-1+len(seen)) * symbolBits
+		return float64(bitsPerByte) * float64(len(input)), model
+	}
+
+	tokens, total := g.Groups(corpus), 0.0
+	for _, key := range Keys(tokens) {
+		set := tokens[key]
+		data, model := encode(set)
+		total += data + model
+	}
+	data, model := encode(g.Stream(cfg.Varint))
+	total += data + model
+	total += float64(len(tokens)) * math.Log2(float64(len(corpus)))
+	return total / float64(len(corpus))
+}
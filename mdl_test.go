@@ -0,0 +1,27 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestMDLFragmented(t *testing.T) {
+	corpus, cfg := curie(t), DefaultFitnessConfig()
+	boundaries := make([]int, 0, len(corpus))
+	for i := 1; i < len(corpus); i++ {
+		boundaries = append(boundaries, i)
+	}
+	fragmented, err := GenomeFromSegmentation(boundaries, len(corpus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	grouped := words(t, corpus)
+	fragmented.ComputeFitness(corpus, cfg)
+	grouped.ComputeFitness(corpus, cfg)
+	complexity := fragmented.Fitness / grouped.Fitness
+	mdl := fragmented.MDLFull(corpus, cfg) / grouped.MDLFull(corpus, cfg)
+	if mdl <= complexity {
+		t.Fatalf("the fragmented grouping is %f times the words under MDL, not more than the %f under complexity", mdl, complexity)
+	}
+}
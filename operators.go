@@ -0,0 +1,174 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Operator is the genetic operator that produced a genome
+type Operator int
+
+const (
+	// OperatorNone marks a genome that wasn't produced by an operator
+	OperatorNone Operator = iota
+	// OperatorMutate nudges a single token
+	OperatorMutate
+	// OperatorSwap crosses over two parents with the -crossover operator
+	OperatorSwap
+	// OperatorCopy copies a token from one parent to another
+	OperatorCopy
+	// NumOperators is the number of operators
+	NumOperators
+)
+
+// String returns the name of the operator
+func (o Operator) String() string {
+	switch o {
+	case OperatorMutate:
+		return "mutate"
+	case OperatorSwap:
+		return "swap"
+	case OperatorCopy:
+		return "copy"
+	}
+	return "none"
+}
+
+// AllOperators are the operators in the order they are drawn from
+var AllOperators = []Operator{OperatorMutate, OperatorSwap, OperatorCopy}
+
+// ParseOperators parses a comma separated list of operator names
+func ParseOperators(names string) ([]Operator, error) {
+	operators := make([]Operator, 0, len(AllOperators))
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, operator := range AllOperators {
+			if operator.String() == name {
+				operators, found = append(operators, operator), true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown operator %q, the operators are mutate, swap and copy", name)
+		}
+	}
+	return operators, nil
+}
+
+// OperatorDelta is the fitness improvement of the children of an operator
+type OperatorDelta struct {
+	Count int
+	Sum   float64
+}
+
+// Mean is the mean fitness delta of a child versus its best parent, negative is an improvement
+func (o OperatorDelta) Mean() float64 {
+	if o.Count == 0 {
+		return 0
+	}
+	return o.Sum / float64(o.Count)
+}
+
+// OperatorDeltas are the fitness deltas of each operator
+type OperatorDeltas [NumOperators]OperatorDelta
+
+// Add accumulates the deltas of the freshly evaluated children in the population and clears their
+// operator so that they are not counted again when they survive
+func (o *OperatorDeltas) Add(genomes []Genome) {
+	for i := range genomes {
+		genome := &genomes[i]
+		if genome.Operator == OperatorNone {
+			continue
+		}
+		delta := &o[genome.Operator]
+		delta.Count++
+		delta.Sum += genome.Fitness - genome.Parent
+		genome.Operator = OperatorNone
+	}
+}
+
+// Accumulate adds the deltas in other to the deltas
+func (o *OperatorDeltas) Accumulate(other *OperatorDeltas) {
+	for i := range o {
+		o[i].Count += other[i].Count
+		o[i].Sum += other[i].Sum
+	}
+}
+
+// Print prints the mean delta of each operator
+func (o *OperatorDeltas) Print() {
+	for i := OperatorMutate; i < NumOperators; i++ {
+		fmt.Printf("%s count=%d mean_delta=%f\n", i, o[i].Count, o[i].Mean())
+	}
+}
+
+// AdaptiveMinimum is the smallest selection probability of an adaptive operator
+const AdaptiveMinimum = .05
+
+// AdaptiveOperators selects operators using adaptive pursuit: each generation the selection
+// probability of the operator whose children improved the most moves toward a maximum at the given
+// rate while the others decay toward AdaptiveMinimum. Only the active Operators are selected
+type AdaptiveOperators struct {
+	Rate          float64
+	Operators     []Operator
+	Probabilities [NumOperators]float64
+}
+
+// NewAdaptiveOperators creates a new adaptive operator selector with uniform probabilities over the
+// operators, or over AllOperators if there are none
+func NewAdaptiveOperators(rate float64, operators ...Operator) *AdaptiveOperators {
+	if len(operators) == 0 {
+		operators = AllOperators
+	}
+	a := AdaptiveOperators{
+		Rate:      rate,
+		Operators: operators,
+	}
+	for _, i := range operators {
+		a.Probabilities[i] = 1 / float64(len(operators))
+	}
+	return &a
+}
+
+// Update pursues the operator with the best mean delta in the generation
+func (a *AdaptiveOperators) Update(deltas *OperatorDeltas) {
+	best := OperatorNone
+	for _, i := range a.Operators {
+		if deltas[i].Count == 0 {
+			continue
+		}
+		if best == OperatorNone || deltas[i].Mean() < deltas[best].Mean() {
+			best = i
+		}
+	}
+	if best == OperatorNone {
+		return
+	}
+
+	maximum := 1 - float64(len(a.Operators)-1)*AdaptiveMinimum
+	for _, i := range a.Operators {
+		target := AdaptiveMinimum
+		if i == best {
+			target = maximum
+		}
+		a.Probabilities[i] += a.Rate * (target - a.Probabilities[i])
+	}
+}
+
+// Select samples an operator
+func (a *AdaptiveOperators) Select(r *rand.Rand) Operator {
+	sample, sum := r.Float64(), 0.0
+	for _, i := range a.Operators {
+		sum += a.Probabilities[i]
+		if sample < sum {
+			return i
+		}
+	}
+	return a.Operators[len(a.Operators)-1]
+}
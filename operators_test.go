@@ -0,0 +1,66 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestOperatorDeltas(t *testing.T) {
+	genomes := []Genome{
+		{Fitness: 4, Operator: OperatorMutate, Parent: 5},
+		{Fitness: 7, Operator: OperatorMutate, Parent: 5},
+		{Fitness: 4, Operator: OperatorSwap, Parent: 3},
+		{Fitness: 1},
+	}
+	var deltas OperatorDeltas
+	deltas.Add(genomes)
+	if d := deltas[OperatorMutate]; d.Count != 2 || d.Sum != 1 || d.Mean() != .5 {
+		t.Fatalf("the mutate delta is %+v", d)
+	}
+	if d := deltas[OperatorSwap]; d.Count != 1 || d.Mean() != 1 {
+		t.Fatalf("the swap delta is %+v", d)
+	}
+	if d := deltas[OperatorCopy]; d.Count != 0 || d.Mean() != 0 {
+		t.Fatalf("the copy delta is %+v", d)
+	}
+	if d := deltas[OperatorNone]; d.Count != 0 {
+		t.Fatalf("%d genomes without an operator are counted", d.Count)
+	}
+
+	// the children are only counted once
+	deltas.Add(genomes)
+	if d := deltas[OperatorMutate]; d.Count != 2 {
+		t.Fatalf("the children are counted again: %+v", d)
+	}
+}
+
+func TestAdaptiveOperators(t *testing.T) {
+	adaptive := NewAdaptiveOperators(.1)
+	initial := adaptive.Probabilities[OperatorCopy]
+	for generation := 0; generation < 100; generation++ {
+		// copy always improves and the other operators always make things worse
+		var deltas OperatorDeltas
+		deltas[OperatorMutate] = OperatorDelta{Count: 10, Sum: 5}
+		deltas[OperatorSwap] = OperatorDelta{Count: 10, Sum: 2}
+		deltas[OperatorCopy] = OperatorDelta{Count: 10, Sum: -3}
+		previous := adaptive.Probabilities[OperatorCopy]
+		adaptive.Update(&deltas)
+		if adaptive.Probabilities[OperatorCopy] < previous {
+			t.Fatalf("the copy probability decreased in generation %d", generation)
+		}
+	}
+	maximum := 1 - 2*AdaptiveMinimum
+	if p := adaptive.Probabilities[OperatorCopy]; p <= initial || maximum-p > .01 {
+		t.Fatalf("the copy probability converged to %f, not %f", p, maximum)
+	}
+	sum := 0.0
+	for _, p := range adaptive.Probabilities {
+		sum += p
+	}
+	if sum < .999 || sum > 1.001 {
+		t.Fatalf("the probabilities sum to %f", sum)
+	}
+}
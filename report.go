@@ -0,0 +1,75 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/pointlander/token/cdf16"
+)
+
+// FitnessReport is an interpretable breakdown of the fitness of a genome
+type FitnessReport struct {
+	// MeanTokenBits is the mean over tokens of the bits per byte of each token's bytes
+	MeanTokenBits float64
+	// StreamBits is the bits per byte of the encoded token id stream
+	StreamBits float64
+	// DistinctTokens is the number of distinct tokens
+	DistinctTokens int
+	// EncodedBytes is the estimated size of the token bytes plus the token id stream
+	EncodedBytes float64
+	// CompressionRatio is EncodedBytes over the size of the corpus
+	CompressionRatio float64
+	// CodedBytes is the size of the token bytes plus the token id stream range coded
+	CodedBytes int
+	// CodedRatio is CodedBytes over the size of the corpus
+	CodedRatio float64
+}
+
+// Fitness is the complexity objective the report breaks down. It matches ComputeFitness without the
+// penalties, and without the clamp of a single token genome to the baseline
+func (f FitnessReport) Fitness() float64 {
+	return f.MeanTokenBits + f.StreamBits
+}
+
+// Report breaks down the fitness of the genome on the corpus, with the context depth and the stream
+// encoding of cfg
+func (g *Genome) Report(corpus []byte, cfg FitnessConfig) FitnessReport {
+	var report FitnessReport
+	bits := func(input []byte) (float64, float64) {
+		complexity := cdf16.NewComplexity(cfg.Depth)
+		bitsPerByte, _ := complexity.ComplexityFull(input)
+		return float64(bitsPerByte), float64(bitsPerByte) * float64(len(input))
+	}
+	coder := cdf16.DefaultCDF16Config()
+	coder.Depth = cfg.Depth
+	coded := func(input []byte) int {
+		return len(cdf16.NewCDF16WithConfig(coder).Encode(input))
+	}
+
+	tokens, total := g.Groups(corpus), 0.0
+	for _, key := range Keys(tokens) {
+		set := tokens[key]
+		bitsPerByte, size := bits(set)
+		report.MeanTokenBits += bitsPerByte
+		total += size
+		report.CodedBytes += coded(set)
+	}
+	report.DistinctTokens = len(tokens)
+	if report.DistinctTokens > 0 {
+		report.MeanTokenBits /= float64(report.DistinctTokens)
+	}
+
+	stream := g.Stream(cfg.Varint)
+	bitsPerByte, size := bits(stream)
+	report.StreamBits = bitsPerByte
+	total += size
+	report.CodedBytes += coded(stream)
+
+	report.EncodedBytes = total / 8
+	if len(corpus) > 0 {
+		report.CompressionRatio = report.EncodedBytes / float64(len(corpus))
+		report.CodedRatio = float64(report.CodedBytes) / float64(len(corpus))
+	}
+	return report
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pointlander/token/cdf16"
+)
+
+func TestReport(t *testing.T) {
+	corpus, cfg := curie(t), DefaultFitnessConfig()
+	g := words(t, corpus)
+	report := g.Report(corpus, cfg)
+	g.ComputeFitness(corpus, cfg)
+	if math.Abs(report.Fitness()-g.Fitness) > 1e-9 {
+		t.Fatalf("the report gives a fitness of %f, not %f", report.Fitness(), g.Fitness)
+	}
+
+	groups, bits := g.Groups(corpus), 0.0
+	if report.DistinctTokens != len(groups) {
+		t.Fatalf("the report has %d tokens, not %d", report.DistinctTokens, len(groups))
+	}
+	for _, key := range Keys(groups) {
+		bits += float64(cdf16.NewComplexity(cfg.Depth).Complexity(groups[key])) * float64(len(groups[key]))
+	}
+	stream := g.Stream(cfg.Varint)
+	bits += report.StreamBits * float64(len(stream))
+	if math.Abs(report.EncodedBytes-bits/8) > 1e-6 {
+		t.Fatalf("the report has %f encoded bytes, not %f", report.EncodedBytes, bits/8)
+	}
+	if report.CompressionRatio != report.EncodedBytes/float64(len(corpus)) ||
+		report.CodedRatio != float64(report.CodedBytes)/float64(len(corpus)) {
+		t.Fatalf("the ratios %f and %f don't match the sizes", report.CompressionRatio, report.CodedRatio)
+	}
+}
+
+func TestReportDepth(t *testing.T) {
+	corpus, cfg := curie(t), DefaultFitnessConfig()
+	cfg.Depth = 0
+	g := words(t, corpus)
+	report := g.Report(corpus, cfg)
+	g.ComputeFitness(corpus, cfg)
+	if math.Abs(report.Fitness()-g.Fitness) > 1e-9 {
+		t.Fatalf("the report at depth 0 gives a fitness of %f, not %f", report.Fitness(), g.Fitness)
+	}
+	// the coded size is measured with order-0 models too
+	coder := cdf16.DefaultCDF16Config()
+	coder.Depth = cfg.Depth
+	groups, coded := g.Groups(corpus), len(cdf16.NewCDF16WithConfig(coder).Encode(g.Stream(cfg.Varint)))
+	for _, key := range Keys(groups) {
+		coded += len(cdf16.NewCDF16WithConfig(coder).Encode(groups[key]))
+	}
+	if report.CodedBytes != coded {
+		t.Fatalf("the report codes %d bytes at depth 0, not %d", report.CodedBytes, coded)
+	}
+	if deep := g.Report(corpus, DefaultFitnessConfig()); deep.CodedBytes == report.CodedBytes {
+		t.Fatalf("the report codes %d bytes at both depths", deep.CodedBytes)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/pointlander/token/cdf16"
+)
+
+// Estimator is an online complexity estimate that scores each symbol before learning it, see
+// cdf16.Complexity.Observe
+type Estimator struct {
+	Model *cdf16.Complexity
+	// Total is the sum of the complexities of the bytes added so far
+	Total float64
+	Count uint64
+}
+
+// NewEstimator creates a new online complexity estimate
+func NewEstimator(depth int) *Estimator {
+	return &Estimator{
+		Model: cdf16.NewComplexity(depth),
+	}
+}
+
+// Add scores and then learns the input
+func (e *Estimator) Add(input []byte) {
+	for _, b := range input {
+		e.Total += float64(e.Model.Observe(b))
+		e.Count++
+	}
+}
+
+// Complexity is the complexity of everything added so far
+func (e *Estimator) Complexity() float64 {
+	if e.Count == 0 {
+		return 0
+	}
+	return e.Total / float64(e.Count)
+}
+
+// Scorer incrementally estimates the fitness of a genome as the corpus streams in.
+// ComputeFitness trains each model on the whole input before scoring it, while the scorer
+// scores each byte before learning it, so the estimate runs above the batch fitness and the
+// gap narrows as more of the corpus arrives. Each token has its own model that starts cold, so the
+// gap is largest for genomes with many short tokens.
+type Scorer struct {
+	Genome *Genome
+	Config FitnessConfig
+	Offset int
+	Tokens map[int64]*Estimator
+	Stream *Estimator
+}
+
+// NewScorer creates a new incremental scorer for the genome with the context depth and the stream
+// encoding of cfg
+func NewScorer(g *Genome, cfg FitnessConfig) *Scorer {
+	return &Scorer{
+		Genome: g,
+		Config: cfg,
+		Tokens: make(map[int64]*Estimator),
+		Stream: NewEstimator(cfg.Depth),
+	}
+}
+
+// AddBytes adds the next chunk of the corpus, bytes past the end of the genome are ignored
+func (s *Scorer) AddBytes(input []byte) {
+	tokens := s.Genome.Tokens
+	buffer := make([]byte, 0, 8)
+	for _, b := range input {
+		if s.Offset >= len(tokens) {
+			break
+		}
+		token := tokens[s.Offset]
+		estimator := s.Tokens[token]
+		if estimator == nil {
+			estimator = NewEstimator(s.Config.Depth)
+			s.Tokens[token] = estimator
+		}
+		estimator.Add([]byte{b})
+		buffer = AppendToken(buffer[:0], token, s.Config.Varint)
+		s.Stream.Add(buffer)
+		s.Offset++
+	}
+}
+
+// Fitness is the running fitness estimate
+func (s *Scorer) Fitness() float64 {
+	if len(s.Tokens) == 0 {
+		return 0
+	}
+	fitness := 0.0
+	for _, estimator := range s.Tokens {
+		fitness += estimator.Complexity()
+	}
+	fitness /= float64(len(s.Tokens))
+	return fitness + s.Stream.Complexity()
+}
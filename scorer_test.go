@@ -0,0 +1,83 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/pointlander/token/cdf16"
+)
+
+// classes tokenizes a corpus into runs of letters, spaces and everything else
+func classes(corpus []byte) Genome {
+	g := Genome{Tokens: make([]int64, len(corpus))}
+	for i, b := range corpus {
+		switch {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z':
+			g.Tokens[i] = 0
+		case b == ' ':
+			g.Tokens[i] = 1
+		default:
+			g.Tokens[i] = 2
+		}
+	}
+	return g
+}
+
+func TestScorer(t *testing.T) {
+	data, err := ioutil.ReadFile("curie.wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, gap := DefaultFitnessConfig(), 0.0
+	for _, length := range []int{1024, 4096, 16384} {
+		corpus := data[:length]
+		g := classes(corpus)
+		scorer := NewScorer(&g, cfg)
+		for i := 0; i < len(corpus); i += 1000 {
+			end := i + 1000
+			if end > len(corpus) {
+				end = len(corpus)
+			}
+			scorer.AddBytes(corpus[i:end])
+		}
+		g.ComputeFitness(corpus, cfg)
+		// the estimate runs above the batch fitness and the gap narrows as the corpus grows
+		estimate := scorer.Fitness()
+		relative := (estimate - g.Fitness) / g.Fitness
+		if relative < 0 || (gap > 0 && relative >= gap) {
+			t.Fatalf("the estimate is %f and the batch fitness %f for %d bytes", estimate, g.Fitness, length)
+		}
+		gap = relative
+	}
+	if gap > .3 {
+		t.Fatalf("the estimate is %.0f%% above the batch fitness", 100*gap)
+	}
+}
+
+func TestEstimator(t *testing.T) {
+	corpus := curie(t)
+	estimator, model := NewEstimator(2), cdf16.NewComplexity(2)
+	// the estimate is the mean complexity that Observe outputs for the bytes of the stream
+	total := 0.0
+	for i := 0; i < len(corpus); i += 100 {
+		end := i + 100
+		if end > len(corpus) {
+			end = len(corpus)
+		}
+		estimator.Add(corpus[i:end])
+		for _, b := range corpus[i:end] {
+			total += float64(model.Observe(b))
+		}
+		if expected := total / float64(end); estimator.Complexity() != expected {
+			t.Fatalf("the estimate is %f after %d bytes, not %f", estimator.Complexity(), end, expected)
+		}
+	}
+	// the estimator's model has learned the stream like the model that observed it directly
+	if score := model.Score(corpus); estimator.Model.Score(corpus) != score {
+		t.Fatalf("the estimator's model scores %f, not %f", estimator.Model.Score(corpus), score)
+	}
+}
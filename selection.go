@@ -0,0 +1,21 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math/rand"
+)
+
+// Tournament samples k genomes uniformly with replacement and returns the index of the fittest
+func Tournament(r *rand.Rand, genomes []Genome, k int) int {
+	best := r.Intn(len(genomes))
+	for i := 1; i < k; i++ {
+		candidate := r.Intn(len(genomes))
+		if genomes[candidate].Fitness < genomes[best].Fitness {
+			best = candidate
+		}
+	}
+	return best
+}
@@ -0,0 +1,34 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestTournament(t *testing.T) {
+	rnd, genomes := rand.New(rand.NewSource(1)), make([]Genome, 10)
+	for i := range genomes {
+		// the genomes are shuffled so that the position doesn't matter
+		genomes[i].Fitness = float64((i * 7) % len(genomes))
+	}
+	const draws = 100000
+	for _, k := range []int{1, 3} {
+		counts := make([]int, len(genomes))
+		for i := 0; i < draws; i++ {
+			counts[int(genomes[Tournament(rnd, genomes, k)].Fitness)]++
+		}
+		// the rank r is selected when it is the best of the k samples
+		n := float64(len(genomes))
+		for rank, count := range counts {
+			expected := math.Pow((n-float64(rank))/n, float64(k)) - math.Pow((n-float64(rank)-1)/n, float64(k))
+			if p := float64(count) / draws; math.Abs(p-expected) > .01 {
+				t.Fatalf("the rank %d is selected with probability %f in tournaments of %d, not %f", rank, p, k, expected)
+			}
+		}
+	}
+}
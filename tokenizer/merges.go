@@ -0,0 +1,90 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tokenizer
+
+import (
+	"sort"
+
+	"github.com/pointlander/token/cdf16"
+)
+
+// MergeCandidates is the number of most frequent adjacent pairs LearnMerges scores for each merge
+const MergeCandidates = 16
+
+// LearnMerges learns up to numMerges byte pair style merges of the corpus. The bytes are the
+// symbols 0 to 255 and merge i creates the symbol 256+i. At each step the MergeCandidates most
+// frequent adjacent pairs are scored by the total complexity of the merged corpus, and the pair
+// that reduces it the most is merged. Learning stops early when no merge reduces the complexity
+func LearnMerges(corpus []byte, numMerges int) [][2]uint16 {
+	if numMerges > cdf16.MaxAlphabet-ByteTokens {
+		numMerges = cdf16.MaxAlphabet - ByteTokens
+	}
+	if numMerges <= 0 || len(corpus) < 2 {
+		return nil
+	}
+	model := cdf16.NewComplexityN(cdf16.CDF16Depth, ByteTokens+numMerges)
+	complexity := func(symbols []rune) float32 {
+		model.Reset()
+		return model.ComplexityRunes(symbols) * float32(len(symbols))
+	}
+
+	symbols := make([]rune, len(corpus))
+	for i, b := range corpus {
+		symbols[i] = rune(b)
+	}
+	total, merges := complexity(symbols), make([][2]uint16, 0, numMerges)
+	for len(merges) < numMerges && len(symbols) > 1 {
+		counts := make(map[[2]rune]int)
+		for i := 1; i < len(symbols); i++ {
+			counts[[2]rune{symbols[i-1], symbols[i]}]++
+		}
+		candidates := make([][2]rune, 0, len(counts))
+		for pair := range counts {
+			candidates = append(candidates, pair)
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if counts[a] != counts[b] {
+				return counts[a] > counts[b]
+			}
+			if a[0] != b[0] {
+				return a[0] < b[0]
+			}
+			return a[1] < b[1]
+		})
+		if len(candidates) > MergeCandidates {
+			candidates = candidates[:MergeCandidates]
+		}
+
+		symbol := rune(ByteTokens + len(merges))
+		var best []rune
+		var pair [2]rune
+		for _, candidate := range candidates {
+			merged := merge(symbols, candidate, symbol)
+			if c := complexity(merged); c < total {
+				total, best, pair = c, merged, candidate
+			}
+		}
+		if best == nil {
+			break
+		}
+		symbols, merges = best, append(merges, [2]uint16{uint16(pair[0]), uint16(pair[1])})
+	}
+	return merges
+}
+
+// merge replaces the non overlapping occurrences of pair in symbols with symbol, scanning from the left
+func merge(symbols []rune, pair [2]rune, symbol rune) []rune {
+	merged := make([]rune, 0, len(symbols))
+	for i := 0; i < len(symbols); i++ {
+		if i+1 < len(symbols) && symbols[i] == pair[0] && symbols[i+1] == pair[1] {
+			merged = append(merged, symbol)
+			i++
+			continue
+		}
+		merged = append(merged, symbols[i])
+	}
+	return merged
+}
@@ -0,0 +1,31 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tokenizer
+
+import (
+	"testing"
+)
+
+func TestLearnMerges(t *testing.T) {
+	corpus := []byte("ababababababab")
+	counts, frequent := make(map[[2]uint16]int), [2]uint16{}
+	for i := 1; i < len(corpus); i++ {
+		pair := [2]uint16{uint16(corpus[i-1]), uint16(corpus[i])}
+		counts[pair]++
+		if counts[pair] > counts[frequent] {
+			frequent = pair
+		}
+	}
+	merges := LearnMerges(corpus, 3)
+	if len(merges) == 0 {
+		t.Fatal("no merges were learned")
+	}
+	if merges[0] != frequent {
+		t.Fatalf("the first merge is %v, not the most frequent pair %v", merges[0], frequent)
+	}
+	if merges := LearnMerges(corpus, 0); merges != nil {
+		t.Fatalf("%v were learned with no merges allowed", merges)
+	}
+}
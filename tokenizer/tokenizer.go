@@ -0,0 +1,81 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tokenizer encodes text with a vocabulary of tokens learned from a corpus
+package tokenizer
+
+// ByteTokens is the number of byte level token ids, learned tokens are numbered after them
+const ByteTokens = 256
+
+// Tokenizer encodes text with the vocabulary learned by a genome
+type Tokenizer struct {
+	Vocabulary map[string]int64
+	Tokens     [][]byte
+	Longest    int
+}
+
+// NewTokenizer creates a tokenizer from the segments of a corpus, such as the contiguous runs of a
+// genome over the corpus it was evolved on. Every distinct segment becomes a token, numbered from
+// ByteTokens in order of first appearance
+func NewTokenizer(segments [][]byte) *Tokenizer {
+	t := Tokenizer{
+		Vocabulary: make(map[string]int64),
+	}
+	for _, segment := range segments {
+		t.Add(segment)
+	}
+	return &t
+}
+
+// Add adds a token to the vocabulary
+func (t *Tokenizer) Add(token []byte) {
+	if _, ok := t.Vocabulary[string(token)]; ok {
+		return
+	}
+	cp := make([]byte, len(token))
+	copy(cp, token)
+	t.Vocabulary[string(cp)] = int64(ByteTokens + len(t.Tokens))
+	t.Tokens = append(t.Tokens, cp)
+	if len(cp) > t.Longest {
+		t.Longest = len(cp)
+	}
+}
+
+// Encode tokenizes text by greedy longest match against the vocabulary. A byte that doesn't start
+// any learned token is encoded as its byte level id, which is the byte value itself
+func (t *Tokenizer) Encode(text []byte) []int64 {
+	ids := make([]int64, 0, len(text))
+	for len(text) > 0 {
+		length := t.Longest
+		if length > len(text) {
+			length = len(text)
+		}
+		for ; length > 0; length-- {
+			if id, ok := t.Vocabulary[string(text[:length])]; ok {
+				ids = append(ids, id)
+				break
+			}
+		}
+		if length == 0 {
+			ids = append(ids, int64(text[0]))
+			length = 1
+		}
+		text = text[length:]
+	}
+	return ids
+}
+
+// Decode reconstructs the text from token ids, ids outside of the vocabulary are skipped
+func (t *Tokenizer) Decode(ids []int64) []byte {
+	text := make([]byte, 0, len(ids))
+	for _, id := range ids {
+		switch {
+		case id >= 0 && id < ByteTokens:
+			text = append(text, byte(id))
+		case id >= ByteTokens && id < int64(ByteTokens+len(t.Tokens)):
+			text = append(text, t.Tokens[id-ByteTokens]...)
+		}
+	}
+	return text
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 The Token Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tokenizer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// words splits text into segments that each end after a space
+func words(text []byte) [][]byte {
+	segments, begin := [][]byte{}, 0
+	for i, b := range text {
+		if b == ' ' {
+			segments = append(segments, text[begin:i+1])
+			begin = i + 1
+		}
+	}
+	if begin < len(text) {
+		segments = append(segments, text[begin:])
+	}
+	return segments
+}
+
+func TestTokenizerRoundTrip(t *testing.T) {
+	data, err := ioutil.ReadFile("../curie.wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	training := data[:1024]
+	tokenizer := NewTokenizer(words(training))
+	ids := tokenizer.Encode(training)
+	if len(ids) >= len(training) {
+		t.Fatalf("%d ids for %d bytes", len(ids), len(training))
+	}
+	for _, id := range ids {
+		if id < ByteTokens {
+			t.Fatalf("the trained text has the byte level id %d", id)
+		}
+	}
+	if decoded := tokenizer.Decode(ids); !bytes.Equal(decoded, training) {
+		t.Fatalf("the decoded text is %q", decoded)
+	}
+
+	binary := []byte{0, 255, 'M', 'a', 1}
+	if decoded := tokenizer.Decode(tokenizer.Encode(binary)); !bytes.Equal(decoded, binary) {
+		t.Fatalf("the decoded bytes are %v", decoded)
+	}
+}
+
+func TestTokenizerUnseen(t *testing.T) {
+	tokenizer := NewTokenizer(words([]byte("the cat sat on the mat ")))
+	unseen := []byte("the mat sat!")
+	ids := tokenizer.Encode(unseen)
+	expected := []int64{tokenizer.Vocabulary["the "], tokenizer.Vocabulary["mat "], 's', 'a', 't', '!'}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("the ids are %v, not %v", ids, expected)
+	}
+	if decoded := tokenizer.Decode(ids); !bytes.Equal(decoded, unseen) {
+		t.Fatalf("the decoded text is %q", decoded)
+	}
+}